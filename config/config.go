@@ -0,0 +1,358 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as "30s" or "5m" in the config file.
+type Duration time.Duration
+
+func (d *Duration) set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.set(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.set(value.Value)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+type Redis struct {
+	Addr     string `json:"addr" yaml:"addr"`
+	Password string `json:"password" yaml:"password"`
+	DB       int    `json:"db" yaml:"db"`
+	// Prefix namespaces the keys, users are kept in <prefix>user:<name>
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+// Store selects where the users are kept: "redis", or "file" for a JSON
+// file at Path on single-box deployments without Redis.
+type Store struct {
+	Type string `json:"type" yaml:"type"`
+	Path string `json:"path" yaml:"path"`
+}
+
+// Cluster syncs the nodes sharing the Redis store over pub/sub.
+type Cluster struct {
+	// NodeID tells the nodes apart, empty disables the sync
+	NodeID string `json:"node_id" yaml:"node_id"`
+	// Ports overrides the port of the users by name on this node
+	Ports map[string]string `json:"ports" yaml:"ports"`
+}
+
+// DomainSet selects how the blocked domains are held: "hash" matches
+// exactly, "cuckoo" is smaller but has false positives. Precheck puts a
+// cuckoo filter in front of the hash set.
+type DomainSet struct {
+	Backend  string `json:"backend" yaml:"backend"`
+	Precheck bool   `json:"precheck" yaml:"precheck"`
+}
+
+// SaltFilter tunes the bloom ring of the salt filter, zero fields keep the
+// defaults. Left empty, the SHADOWSOCKS_SF_* environment variables still apply.
+type SaltFilter struct {
+	// a negative capacity disables the salt filter
+	Capacity float64 `json:"capacity" yaml:"capacity"`
+	FPR      float64 `json:"fpr" yaml:"fpr"`
+	Slot     int     `json:"slot" yaml:"slot"`
+}
+
+// RateLimit is the default bandwidth in bytes per second of the users
+// without their own limits. 0 means unlimited.
+type RateLimit struct {
+	Up   int64 `json:"up" yaml:"up"`
+	Down int64 `json:"down" yaml:"down"`
+}
+
+// RPCAuth holds the secrets by access ID the RPC calls are signed with.
+// No keys disables authentication.
+type RPCAuth struct {
+	Keys map[string]string `json:"keys" yaml:"keys"`
+	// Window is how far the timestamp of a call may be off
+	Window Duration `json:"window" yaml:"window"`
+}
+
+type Config struct {
+	RPC              string     `json:"rpc" yaml:"rpc"`
+	RPCAuth          RPCAuth    `json:"rpc_auth" yaml:"rpc_auth"`
+	REST             string     `json:"rest" yaml:"rest"`
+	GRPC             string     `json:"grpc" yaml:"grpc"`
+	Store            Store      `json:"store" yaml:"store"`
+	Redis            Redis      `json:"redis" yaml:"redis"`
+	Cluster          Cluster    `json:"cluster" yaml:"cluster"`
+	BindIP           string     `json:"bind_ip" yaml:"bind_ip"`
+	Blocklists       []string   `json:"blocklists" yaml:"blocklists"`
+	BlocklistCache   string     `json:"blocklist_cache" yaml:"blocklist_cache"`
+	BlocklistRefresh Duration   `json:"blocklist_refresh" yaml:"blocklist_refresh"`
+	DomainSet        DomainSet  `json:"domain_set" yaml:"domain_set"`
+	NATTimeout       Duration   `json:"nat_timeout" yaml:"nat_timeout"`
+	FlushInterval    Duration   `json:"flush_interval" yaml:"flush_interval"`
+	DrainTimeout     Duration   `json:"drain_timeout" yaml:"drain_timeout"`
+	SaltFilter       SaltFilter `json:"salt_filter" yaml:"salt_filter"`
+	RateLimit        RateLimit  `json:"rate_limit" yaml:"rate_limit"`
+	// Quiet silences the connection logs of the server
+	Quiet bool `json:"quiet" yaml:"quiet"`
+}
+
+func Default() *Config {
+	return &Config{
+		RPC: "127.0.0.1:50899",
+		RPCAuth: RPCAuth{
+			Window: Duration(time.Minute),
+		},
+		Store: Store{
+			Type: "redis",
+			Path: "users.json",
+		},
+		Redis: Redis{
+			Addr:   "127.0.0.1:6379",
+			Prefix: "ss:",
+		},
+		BindIP: "0.0.0.0",
+		Blocklists: []string{
+			"https://zerodot1.gitlab.io/CoinBlockerLists/list.txt",
+		},
+		BlocklistCache:   "blocklist-cache",
+		BlocklistRefresh: Duration(24 * time.Hour),
+		DomainSet: DomainSet{
+			Backend: "hash",
+		},
+		NATTimeout:    Duration(5 * time.Minute),
+		FlushInterval: Duration(time.Minute),
+		DrainTimeout:  Duration(10 * time.Second),
+	}
+}
+
+// Load reads the config file at path over the defaults.
+// Files ending in .json are JSON, anything else is YAML.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(b, c)
+	} else {
+		err = yaml.Unmarshal(b, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+func checkAddr(name, addr string) error {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return fmt.Errorf("%s: invalid address %q", name, addr)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	add(checkAddr("rpc", c.RPC))
+	for id, secret := range c.RPCAuth.Keys {
+		if id == "" || secret == "" {
+			add(fmt.Errorf("rpc_auth.keys: empty access ID or secret"))
+		}
+	}
+	if c.RPCAuth.Window <= 0 {
+		add(fmt.Errorf("rpc_auth.window: must be positive"))
+	}
+	if c.REST != "" {
+		add(checkAddr("rest", c.REST))
+	}
+	if c.GRPC != "" {
+		add(checkAddr("grpc", c.GRPC))
+	}
+	switch c.Store.Type {
+	case "redis":
+		add(checkAddr("redis.addr", c.Redis.Addr))
+		if c.Redis.DB < 0 {
+			add(fmt.Errorf("redis.db: must not be negative"))
+		}
+		if c.Redis.Prefix == "" {
+			add(fmt.Errorf("redis.prefix: must not be empty"))
+		}
+	case "file":
+		if c.Store.Path == "" {
+			add(fmt.Errorf("store.path: must not be empty"))
+		}
+	default:
+		add(fmt.Errorf("store.type: unknown store %q", c.Store.Type))
+	}
+	if c.Cluster.NodeID != "" && c.Store.Type != "redis" {
+		add(fmt.Errorf("cluster.node_id: requires the redis store"))
+	}
+	if strings.ContainsAny(c.Cluster.NodeID, ": ") {
+		add(fmt.Errorf("cluster.node_id: invalid node ID %q", c.Cluster.NodeID))
+	}
+	for name, port := range c.Cluster.Ports {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			add(fmt.Errorf("cluster.ports: invalid port %q of %s", port, name))
+		}
+	}
+	if net.ParseIP(c.BindIP) == nil {
+		add(fmt.Errorf("bind_ip: invalid IP %q", c.BindIP))
+	}
+	for _, v := range c.Blocklists {
+		u, err := url.Parse(v)
+		switch {
+		case err != nil:
+			add(fmt.Errorf("blocklists: invalid URL %q", v))
+		case u.Scheme == "file":
+			if u.Opaque == "" && (u.Path == "" || u.Host != "" && u.Host != "localhost") {
+				add(fmt.Errorf("blocklists: invalid file URL %q", v))
+			}
+		case (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			add(fmt.Errorf("blocklists: invalid URL %q", v))
+		}
+		if err == nil {
+			switch u.Fragment {
+			case "", "plain", "hosts", "adblock", "dnsmasq":
+			default:
+				add(fmt.Errorf("blocklists: unknown format %q of %s", u.Fragment, v))
+			}
+		}
+	}
+	switch c.DomainSet.Backend {
+	case "hash":
+	case "cuckoo":
+		if c.DomainSet.Precheck {
+			add(fmt.Errorf("domain_set.precheck: only for the hash backend"))
+		}
+	default:
+		add(fmt.Errorf("domain_set.backend: unknown backend %q", c.DomainSet.Backend))
+	}
+	if c.NATTimeout <= 0 {
+		add(fmt.Errorf("nat_timeout: must be positive"))
+	}
+	if c.FlushInterval < 0 {
+		add(fmt.Errorf("flush_interval: must not be negative"))
+	}
+	if c.BlocklistRefresh < 0 {
+		add(fmt.Errorf("blocklist_refresh: must not be negative"))
+	}
+	if c.DrainTimeout < 0 {
+		add(fmt.Errorf("drain_timeout: must not be negative"))
+	}
+	if fpr := c.SaltFilter.FPR; fpr < 0 || fpr >= 1 {
+		add(fmt.Errorf("salt_filter.fpr: must be in (0, 1)"))
+	}
+	if c.RateLimit.Up < 0 || c.RateLimit.Down < 0 {
+		add(fmt.Errorf("rate_limit: must not be negative"))
+	}
+	if c.SaltFilter.Slot < 0 {
+		add(fmt.Errorf("salt_filter.slot: must be positive"))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Changes tells which settings differ from old, split into the ones
+// applied live and the ones requiring a restart.
+func (c *Config) Changes(old *Config) (live, restart []string) {
+	for _, v := range []struct {
+		name    string
+		changed bool
+		live    bool
+	}{
+		{"rpc", c.RPC != old.RPC, false},
+		{"rest", c.REST != old.REST, false},
+		{"grpc", c.GRPC != old.GRPC, false},
+		{"store", c.Store != old.Store, false},
+		{"redis", c.Redis != old.Redis, false},
+		{"cluster", !c.Cluster.equal(old.Cluster), false},
+		{"rpc_auth", !c.RPCAuth.equal(old.RPCAuth), true},
+		{"bind_ip", c.BindIP != old.BindIP, false},
+		{"salt_filter", c.SaltFilter != old.SaltFilter, false},
+		{"domain_set", c.DomainSet != old.DomainSet, false},
+		{"blocklists", strings.Join(c.Blocklists, "\n") != strings.Join(old.Blocklists, "\n"), true},
+		{"blocklist_cache", c.BlocklistCache != old.BlocklistCache, true},
+		{"blocklist_refresh", c.BlocklistRefresh != old.BlocklistRefresh, true},
+		{"nat_timeout", c.NATTimeout != old.NATTimeout, true},
+		{"flush_interval", c.FlushInterval != old.FlushInterval, true},
+		{"drain_timeout", c.DrainTimeout != old.DrainTimeout, true},
+		{"rate_limit", c.RateLimit != old.RateLimit, true},
+		{"quiet", c.Quiet != old.Quiet, true},
+	} {
+		switch {
+		case !v.changed:
+		case v.live:
+			live = append(live, v.name)
+		default:
+			restart = append(restart, v.name)
+		}
+	}
+	return
+}
+
+func (a RPCAuth) equal(b RPCAuth) bool {
+	if a.Window != b.Window || len(a.Keys) != len(b.Keys) {
+		return false
+	}
+	for k, v := range a.Keys {
+		if b.Keys[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Cluster) equal(b Cluster) bool {
+	if a.NodeID != b.NodeID || len(a.Ports) != len(b.Ports) {
+		return false
+	}
+	for k, v := range a.Ports {
+		if b.Ports[k] != v {
+			return false
+		}
+	}
+	return true
+}
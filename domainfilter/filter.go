@@ -1,100 +1,227 @@
-package domainfilter
-
-import (
-	"bufio"
-	"context"
-	"log"
-	"net/http"
-	"sync"
-	"time"
-
-	cuckoo "github.com/seiflotfy/cuckoofilter"
-)
-
-type DomainFilter struct {
-	filter *cuckoo.Filter
-}
-
-var (
-	lock          sync.Mutex
-	DefaultFilter *DomainFilter
-	filterInit    sync.Once
-	domainList    = []string{
-		"https://zerodot1.gitlab.io/CoinBlockerLists/list.txt",
-	}
-)
-
-func New(domainList []string) *DomainFilter {
-	_filter := cuckoo.NewFilter(1000000)
-	_df := &DomainFilter{
-		filter: _filter,
-	}
-	_df.AddDomainList(domainList)
-	return _df
-}
-
-func (d *DomainFilter) Lookup(domain []byte) bool {
-	return d.filter.Lookup(domain)
-}
-
-func (d *DomainFilter) Reset() {
-	d.filter.Reset()
-}
-
-func (d *DomainFilter) AddDomainList(domainList []string) {
-	var scanner *bufio.Scanner
-	addHook := func(v string) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-		req, err := http.NewRequestWithContext(ctx, "GET", v, nil)
-		if err != nil {
-			return
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return
-		}
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		defer resp.Body.Close()
-		scanner = bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			//log.Println(scanner.Text())
-			d.filter.InsertUnique(scanner.Bytes())
-		}
-	}
-	for _, v := range domainList {
-		addHook(v)
-		scanner = nil
-	}
-}
-
-func getFilter() *DomainFilter {
-	filterInit.Do(func() {
-		DefaultFilter = New(domainList)
-	})
-	return DefaultFilter
-}
-
-func CheckDomain(hostname []byte) bool {
-	return getFilter().Lookup(hostname)
-}
-
-func UpgradeFilter() {
-	lock.Lock()
-	defer lock.Unlock()
-	if DefaultFilter != nil {
-		DefaultFilter.Reset()
-		DefaultFilter.AddDomainList(domainList)
-	}
-}
-
-func AddFilter(List []string) {
-	lock.Lock()
-	defer lock.Unlock()
-	getFilter().AddDomainList(List)
-	domainList = append(domainList, List...)
-
-}
+package domainfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainFilter blocks a domain listed and its subdomains, the subdomains
+// only of "*.domain", and the domains matching "regexp:" or "/.../"
+// rules or containing "keyword:" rules.
+type DomainFilter struct {
+	set     domainSet
+	backend string
+
+	rulesLock sync.RWMutex
+	regexps   []*regexp.Regexp
+	keywords  []string
+}
+
+var (
+	// optLock guards the settings of the filters built
+	optLock  sync.RWMutex
+	backend  = BackendHash
+	precheck bool
+	// cacheDir keeps the last remote lists fetched, empty disables it.
+	cacheDir string
+)
+
+func New(domainList []string) *DomainFilter {
+	_df := newFilter()
+	_df.AddDomainList(domainList)
+	return _df
+}
+
+func newFilter() *DomainFilter {
+	optLock.RLock()
+	b, pre := backend, precheck
+	optLock.RUnlock()
+	_df := &DomainFilter{
+		backend: b,
+	}
+	if b == BackendCuckoo {
+		_df.set = newCuckooSet()
+	} else {
+		_df.set = newHashSet(pre)
+	}
+	return _df
+}
+
+// SetBackend selects the domain set, see BackendHash and BackendCuckoo.
+// precheck puts a cuckoo filter in front of the hash set.
+// It must be called before the filter is first used.
+func SetBackend(b string, pre bool) {
+	optLock.Lock()
+	defer optLock.Unlock()
+	backend, precheck = b, pre
+}
+
+// Stats describes the domain set of a filter.
+type Stats struct {
+	Backend string
+	Domains int
+	// Memory is an estimate in bytes
+	Memory int64
+}
+
+func (d *DomainFilter) Stats() Stats {
+	return Stats{
+		Backend: d.backend,
+		Domains: d.set.len(),
+		Memory:  d.set.memory(),
+	}
+}
+
+// normalize lowercases the domain and drops the trailing dot.
+func normalize(domain []byte) []byte {
+	b := bytes.ToLower(domain)
+	return bytes.TrimSuffix(b, []byte("."))
+}
+
+func (d *DomainFilter) Lookup(domain []byte) bool {
+	host := normalize(domain)
+	if d.set.lookup(host) {
+		return true
+	}
+	// walk the labels from the right
+	wildcard := make([]byte, 0, len(host)+1)
+	for i := len(host) - 1; i > 0; i-- {
+		if host[i] != '.' {
+			continue
+		}
+		parent := host[i+1:]
+		wildcard = append(append(wildcard[:0], "*."...), parent...)
+		if d.set.lookup(parent) || d.set.lookup(wildcard) {
+			return true
+		}
+	}
+	return d.matchRules(host)
+}
+
+func (d *DomainFilter) matchRules(host []byte) bool {
+	d.rulesLock.RLock()
+	defer d.rulesLock.RUnlock()
+	for _, k := range d.keywords {
+		if bytes.Contains(host, []byte(k)) {
+			return true
+		}
+	}
+	for _, re := range d.regexps {
+		if re.Match(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DomainFilter) Reset() {
+	d.set.reset()
+	d.rulesLock.Lock()
+	d.regexps, d.keywords = nil, nil
+	d.rulesLock.Unlock()
+}
+
+// Add adds a rule, see DomainFilter.
+func (d *DomainFilter) Add(rule string) error {
+	rule = strings.TrimSpace(rule)
+	switch {
+	case rule == "":
+		return nil
+	case strings.HasPrefix(rule, "keyword:"):
+		k := strings.ToLower(strings.TrimPrefix(rule, "keyword:"))
+		if k == "" {
+			return errors.New("empty keyword")
+		}
+		d.rulesLock.Lock()
+		d.keywords = append(d.keywords, k)
+		d.rulesLock.Unlock()
+	case strings.HasPrefix(rule, "regexp:"),
+		len(rule) > 2 && rule[0] == '/' && rule[len(rule)-1] == '/':
+		expr := strings.TrimPrefix(rule, "regexp:")
+		if expr == rule {
+			expr = rule[1 : len(rule)-1]
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		d.rulesLock.Lock()
+		d.regexps = append(d.regexps, re)
+		d.rulesLock.Unlock()
+	default:
+		domain, err := cleanDomain(rule)
+		if err != nil {
+			return err
+		}
+		d.set.insert([]byte(domain))
+	}
+	return nil
+}
+
+// Report counts the lines of a source added to the filter.
+type Report struct {
+	Source   string
+	Format   Format
+	Accepted int
+	Rejected int
+	// Cached tells the remote list was read from the cache
+	Cached bool
+	// Err is the error reading the source
+	Err error
+}
+
+// maxLine is the longest line of a blocklist.
+const maxLine = 1 << 20
+
+// addList adds the lines of a blocklist in format f.
+func (d *DomainFilter) addList(r io.Reader, f Format, rep *Report) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLine)
+	var first error
+	for scanner.Scan() {
+		rules, err := parseLine(f, scanner.Text())
+		for _, rule := range rules {
+			if err == nil {
+				err = d.Add(rule)
+			}
+		}
+		switch {
+		case err != nil:
+			rep.Rejected++
+			if first == nil {
+				first = fmt.Errorf("%q: %w", scanner.Text(), err)
+			}
+		case rules != nil:
+			rep.Accepted++
+		}
+	}
+	if first != nil {
+		log.Println(rep.Source, "first rejected line", first)
+	}
+	return scanner.Err()
+}
+
+// AddDomainList adds the blocklists, see Format. A remote list which
+// can't be fetched is read from the cache.
+func (d *DomainFilter) AddDomainList(domainList []string) []Report {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	reports := make([]Report, 0, len(domainList))
+	for _, v := range domainList {
+		s := newSource(v)
+		_, s.err = s.check(ctx, true)
+		reports = append(reports, s.add(d))
+	}
+	st := d.Stats()
+	log.Printf("Domain filter: %d domains in %s, ~%d KiB", st.Domains, st.Backend, st.Memory>>10)
+	return reports
+}
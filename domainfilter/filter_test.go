@@ -0,0 +1,132 @@
+package domainfilter
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(local, []byte("local.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote := &list{body: "remote.com\n"}
+	srv := httptest.NewServer(remote)
+	defer srv.Close()
+	resetDefault()
+	SetCacheDir(t.TempDir())
+	defer SetCacheDir("")
+
+	d := New([]string{"file://" + local, srv.URL})
+	if !d.Lookup([]byte("local.com")) || !d.Lookup([]byte("remote.com")) {
+		t.Fatal("lists not added")
+	}
+
+	// the remote fails, its cached copy is added
+	remote.set("", true)
+	d = New([]string{srv.URL})
+	if !d.Lookup([]byte("remote.com")) {
+		t.Fatal("cached list not added")
+	}
+	d.Reset()
+	if d.Lookup([]byte("remote.com")) || d.Stats().Domains != 0 {
+		t.Fatal("filter not reset")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, b := range []string{BackendHash, BackendCuckoo} {
+		t.Run(b, func(t *testing.T) {
+			SetBackend(b, false)
+			defer SetBackend(BackendHash, false)
+			d := newFilter()
+			for _, rule := range []string{
+				"Example.COM.",
+				"*.wild.org",
+				"keyword:tracker",
+				"regexp:^ads[0-9]+\\.",
+				"/^cdn-[a-z]+\\.net$/",
+			} {
+				if err := d.Add(rule); err != nil {
+					t.Fatalf("%q: %v", rule, err)
+				}
+			}
+			for _, tc := range []struct {
+				domain string
+				want   bool
+			}{
+				{"example.com", true},
+				{"EXAMPLE.com.", true},
+				{"www.example.com", true},
+				{"a.b.example.com", true},
+				{"example.com.evil.net", false},
+				{"notexample.com", false},
+				{"wild.org", false},
+				{"www.wild.org", true},
+				{"a.b.wild.org", true},
+				{"mytracker.io", true},
+				{"ads42.example.net", true},
+				{"ads.example.net", false},
+				{"cdn-abc.net", true},
+				{"cdn-abc.net.org", false},
+				{"other.org", false},
+			} {
+				if got := d.Lookup([]byte(tc.domain)); got != tc.want {
+					t.Errorf("%s: %v, want %v", tc.domain, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddRejects(t *testing.T) {
+	d := newFilter()
+	for _, rule := range []string{
+		"keyword:",
+		"regexp:(",
+		"/[/",
+		"exa mple.com",
+		"a..b",
+		"*.",
+		strings.Repeat("a", 64) + ".com",
+		"bad!.com",
+	} {
+		if err := d.Add(rule); err == nil {
+			t.Errorf("%q accepted", rule)
+		}
+	}
+	if err := d.Add("  "); err != nil {
+		t.Errorf("blank rule: %v", err)
+	}
+	if n := d.Stats().Domains; n != 0 {
+		t.Errorf("%d domains added", n)
+	}
+}
+
+func TestAddListCounts(t *testing.T) {
+	d := newFilter()
+	list := `# comment
+example.com
+0.0.0.0 a.com b.com
+||ads.net^
+
+bad!.com
+||ads.net/path
+keyword:track
+`
+	rep := Report{Source: "test"}
+	if err := d.addList(strings.NewReader(list), FormatAuto, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Accepted != 4 || rep.Rejected != 2 {
+		t.Fatalf("%d accepted, %d rejected, want 4 and 2", rep.Accepted, rep.Rejected)
+	}
+	for _, domain := range []string{"example.com", "a.com", "b.com", "ads.net", "tracking.org"} {
+		if !d.Lookup([]byte(domain)) {
+			t.Errorf("%s not blocked", domain)
+		}
+	}
+}
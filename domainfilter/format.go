@@ -0,0 +1,222 @@
+package domainfilter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Format is the syntax of a blocklist. It is declared by the fragment of
+// the source, e.g. "https://example.com/hosts.txt#hosts", and detected
+// line by line without one.
+type Format string
+
+const (
+	FormatAuto Format = ""
+	// FormatPlain is a domain or a rule of DomainFilter per line.
+	FormatPlain Format = "plain"
+	// FormatHosts is "0.0.0.0 domain ..." per line.
+	FormatHosts Format = "hosts"
+	// FormatAdBlock is "||domain^" per line, other rules are rejected.
+	FormatAdBlock Format = "adblock"
+	// FormatDnsmasq is "address=/domain/..." per line.
+	FormatDnsmasq Format = "dnsmasq"
+)
+
+var errBadFormat = errors.New("unknown format")
+
+// ParseSource splits the format off a blocklist source.
+func ParseSource(src string) (string, Format, error) {
+	i := strings.LastIndexByte(src, '#')
+	if i < 0 {
+		return src, FormatAuto, nil
+	}
+	f := Format(src[i+1:])
+	switch f {
+	case FormatAuto, FormatPlain, FormatHosts, FormatAdBlock, FormatDnsmasq:
+		return src[:i], f, nil
+	}
+	return "", f, fmt.Errorf("%w %q", errBadFormat, f)
+}
+
+// idnaProfile converts the internationalized domains to punycode, letting
+// the underscores of the blocklists through.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// hostsLocal are the names of the hosts files not to block.
+var hostsLocal = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// cleanDomain lowercases a domain, a "*." wildcard included, and converts
+// it to punycode.
+func cleanDomain(s string) (string, error) {
+	s = strings.TrimSuffix(s, ".")
+	prefix := ""
+	if strings.HasPrefix(s, "*.") {
+		prefix, s = "*.", s[2:]
+	}
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		s = strings.ToLower(s)
+	} else {
+		var err error
+		if s, err = idnaProfile.ToASCII(s); err != nil {
+			return "", err
+		}
+	}
+	if s == "" || len(s) > 253 {
+		return "", fmt.Errorf("invalid domain %q", prefix+s)
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("invalid domain %q", prefix+s)
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+				return "", fmt.Errorf("invalid domain %q", prefix+s)
+			}
+		}
+	}
+	return prefix + s, nil
+}
+
+// stripComment drops a "#" comment preceded by a space.
+func stripComment(line string) string {
+	for i := 1; i < len(line); i++ {
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// detect guesses the format of a line.
+func detect(line string) Format {
+	switch {
+	case strings.HasPrefix(line, "||"), strings.HasPrefix(line, "|"),
+		strings.HasPrefix(line, "@@"), strings.Contains(line, "##"):
+		return FormatAdBlock
+	case strings.HasPrefix(line, "address="), strings.HasPrefix(line, "server="):
+		return FormatDnsmasq
+	}
+	if i := strings.IndexAny(line, " \t"); i > 0 && net.ParseIP(line[:i]) != nil {
+		return FormatHosts
+	}
+	return FormatPlain
+}
+
+// parseLine returns the rules of a line, none for a comment or a local
+// name of a hosts file.
+func parseLine(f Format, line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == '!' || line[0] == '[' {
+		return nil, nil
+	}
+	if f == FormatAuto {
+		f = detect(line)
+	}
+	switch f {
+	case FormatHosts:
+		return parseHosts(line)
+	case FormatAdBlock:
+		return parseAdBlock(line)
+	case FormatDnsmasq:
+		return parseDnsmasq(line)
+	}
+	line = strings.TrimSpace(stripComment(line))
+	if strings.HasPrefix(line, "keyword:") || strings.HasPrefix(line, "regexp:") ||
+		len(line) > 2 && line[0] == '/' && line[len(line)-1] == '/' {
+		return []string{line}, nil
+	}
+	d, err := cleanDomain(line)
+	if err != nil {
+		return nil, err
+	}
+	return []string{d}, nil
+}
+
+func parseHosts(line string) ([]string, error) {
+	fields := strings.Fields(stripComment(line))
+	if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+		return nil, errors.New("not a hosts entry")
+	}
+	var rules []string
+	for _, v := range fields[1:] {
+		if hostsLocal[strings.ToLower(v)] {
+			continue
+		}
+		d, err := cleanDomain(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, d)
+	}
+	return rules, nil
+}
+
+// parseAdBlock accepts "||domain^", optionally "$important" or "$all",
+// the other options narrow the rule to some requests of the domain.
+func parseAdBlock(line string) ([]string, error) {
+	if !strings.HasPrefix(line, "||") {
+		return nil, errors.New("unsupported adblock rule")
+	}
+	rule := line[2:]
+	if i := strings.IndexByte(rule, '$'); i >= 0 {
+		if opt := rule[i+1:]; opt != "important" && opt != "all" {
+			return nil, errors.New("unsupported adblock options")
+		}
+		rule = rule[:i]
+	}
+	rule = strings.TrimSuffix(rule, "|")
+	if !strings.HasSuffix(rule, "^") {
+		return nil, errors.New("unsupported adblock rule")
+	}
+	d, err := cleanDomain(strings.TrimSuffix(rule, "^"))
+	if err != nil {
+		return nil, err
+	}
+	return []string{d}, nil
+}
+
+// parseDnsmasq accepts "address=/domain/.../target", whatever the target.
+func parseDnsmasq(line string) ([]string, error) {
+	rule := strings.TrimSpace(stripComment(line))
+	if !strings.HasPrefix(rule, "address=/") {
+		return nil, errors.New("unsupported dnsmasq option")
+	}
+	parts := strings.Split(strings.TrimPrefix(rule, "address="), "/")
+	if len(parts) < 3 {
+		return nil, errors.New("invalid dnsmasq address")
+	}
+	var rules []string
+	for _, v := range parts[1 : len(parts)-1] {
+		d, err := cleanDomain(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, d)
+	}
+	return rules, nil
+}
@@ -0,0 +1,120 @@
+package domainfilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	for _, tc := range []struct {
+		format Format
+		line   string
+		want   []string
+		bad    bool
+	}{
+		// comments and blanks
+		{FormatAuto, "", nil, false},
+		{FormatAuto, "   ", nil, false},
+		{FormatAuto, "# comment", nil, false},
+		{FormatAuto, "! adblock comment", nil, false},
+		{FormatAuto, "[Adblock Plus 2.0]", nil, false},
+
+		// plain
+		{FormatAuto, "Example.COM", []string{"example.com"}, false},
+		{FormatAuto, "example.com. # trailing", []string{"example.com"}, false},
+		{FormatAuto, "*.example.com", []string{"*.example.com"}, false},
+		{FormatAuto, "keyword:ads", []string{"keyword:ads"}, false},
+		{FormatAuto, "/^ads\\./", []string{"/^ads\\./"}, false},
+		{FormatAuto, "bücher.de", []string{"xn--bcher-kva.de"}, false},
+		{FormatAuto, "*.例え.jp", []string{"*.xn--r8jz45g.jp"}, false},
+		{FormatAuto, "_dmarc.example.com", []string{"_dmarc.example.com"}, false},
+		{FormatPlain, "bad!.com", nil, true},
+		{FormatPlain, "a..b", nil, true},
+		{FormatPlain, "0.0.0.0 a.com", nil, true},
+
+		// hosts
+		{FormatAuto, "0.0.0.0 a.com", []string{"a.com"}, false},
+		{FormatAuto, "127.0.0.1\ta.com B.com # two", []string{"a.com", "b.com"}, false},
+		{FormatAuto, "::1 a.com", []string{"a.com"}, false},
+		{FormatAuto, "127.0.0.1 localhost", nil, false},
+		{FormatAuto, "0.0.0.0 0.0.0.0", nil, false},
+		{FormatAuto, "255.255.255.255 broadcasthost", nil, false},
+		{FormatHosts, "a.com", nil, true},
+		{FormatHosts, "0.0.0.0 bad!.com", nil, true},
+
+		// adblock
+		{FormatAuto, "||ads.example.com^", []string{"ads.example.com"}, false},
+		{FormatAuto, "||ads.example.com^|", []string{"ads.example.com"}, false},
+		{FormatAuto, "||ads.example.com^$important", []string{"ads.example.com"}, false},
+		{FormatAuto, "||ads.example.com^$all", []string{"ads.example.com"}, false},
+		{FormatAuto, "||ads.example.com^$third-party", nil, true},
+		{FormatAuto, "||ads.example.com/banner", nil, true},
+		{FormatAuto, "|https://ads.example.com|", nil, true},
+		{FormatAuto, "@@||good.example.com^", nil, true},
+		{FormatAuto, "example.com##.banner", nil, true},
+		{FormatAdBlock, "example.com", nil, true},
+
+		// dnsmasq
+		{FormatAuto, "address=/ads.com/0.0.0.0", []string{"ads.com"}, false},
+		{FormatAuto, "address=/a.com/b.com/", []string{"a.com", "b.com"}, false},
+		{FormatAuto, "address=/a.com/ # comment", []string{"a.com"}, false},
+		{FormatAuto, "server=/a.com/1.1.1.1", nil, true},
+		{FormatAuto, "address=/a.com", nil, true},
+		{FormatDnsmasq, "a.com", nil, true},
+	} {
+		got, err := parseLine(tc.format, tc.line)
+		if tc.bad {
+			if err == nil {
+				t.Errorf("%q (%s): accepted as %q", tc.line, tc.format, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q (%s): %q %v, want %q", tc.line, tc.format, got, err, tc.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for line, want := range map[string]Format{
+		"example.com":           FormatPlain,
+		"keyword:ads":           FormatPlain,
+		"0.0.0.0 example.com":   FormatHosts,
+		"::1 example.com":       FormatHosts,
+		"||example.com^":        FormatAdBlock,
+		"@@||example.com^":      FormatAdBlock,
+		"example.com##.ad":      FormatAdBlock,
+		"address=/a.com/":       FormatDnsmasq,
+		"server=/a.com/1.1.1.1": FormatDnsmasq,
+	} {
+		if got := detect(line); got != want {
+			t.Errorf("%q: %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	for _, tc := range []struct {
+		src, url string
+		format   Format
+		bad      bool
+	}{
+		{"https://a.com/list.txt", "https://a.com/list.txt", FormatAuto, false},
+		{"https://a.com/hosts#hosts", "https://a.com/hosts", FormatHosts, false},
+		{"file:///etc/list#adblock", "file:///etc/list", FormatAdBlock, false},
+		{"https://a.com/list#dnsmasq", "https://a.com/list", FormatDnsmasq, false},
+		{"https://a.com/list#plain", "https://a.com/list", FormatPlain, false},
+		{"https://a.com/list#json", "", "", true},
+	} {
+		url, f, err := ParseSource(tc.src)
+		if tc.bad {
+			if err == nil {
+				t.Errorf("%s: accepted", tc.src)
+			}
+			continue
+		}
+		if err != nil || url != tc.url || f != tc.format {
+			t.Errorf("%s: %q %q %v", tc.src, url, f, err)
+		}
+	}
+}
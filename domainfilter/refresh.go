@@ -0,0 +1,353 @@
+package domainfilter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The default filter is rebuilt off to the side from the sources and
+// swapped in, the lookups never see a filter being built.
+var (
+	// lock serializes the builds and guards the variables below
+	lock       sync.Mutex
+	current    atomic.Value
+	filterInit sync.Once
+	domainList = []string{
+		"https://zerodot1.gitlab.io/CoinBlockerLists/list.txt",
+	}
+	sources = map[string]*source{}
+	// builtList is the domainList of the filter in use
+	builtList string
+	built     time.Time
+	// pending is set when a source changed but the filter was kept
+	pending bool
+	// statusLock guards status, a copy of the last check made by rebuild
+	statusLock      sync.Mutex
+	status          Status
+	tickLock        sync.Mutex
+	ticker          *time.Ticker
+	stopRefresh     chan struct{}
+	refreshInterval time.Duration
+)
+
+// source is the last check of a list of the default filter.
+type source struct {
+	src    string
+	url    string
+	format Format
+	// path is set for a local list
+	path string
+	// invalid is the error parsing src
+	invalid error
+	// data is the remote list, fetched again only if modified
+	data         []byte
+	etag         string
+	lastModified string
+	cached       bool
+
+	checked, updated time.Time
+	err              error
+	report           Report
+}
+
+func newSource(src string) *source {
+	s := &source{src: src}
+	var u *url.URL
+	s.url, s.format, s.invalid = ParseSource(src)
+	if s.invalid == nil {
+		u, s.invalid = url.Parse(s.url)
+	}
+	if s.invalid == nil && u.Scheme == "file" {
+		s.path, s.invalid = localPath(u)
+	}
+	s.report = Report{Source: src, Format: s.format}
+	return s
+}
+
+// check fetches a remote list if modified, or the cached one if initial
+// and it was never fetched. It tells whether the list changed.
+func (s *source) check(ctx context.Context, initial bool) (bool, error) {
+	if s.invalid != nil {
+		return false, s.invalid
+	}
+	if s.path != "" {
+		// the local lists changed are rebuilt by the watcher
+		_, err := os.Stat(s.path)
+		return false, err
+	}
+	data, etag, lastModified, err := fetch(ctx, s.url, s.etag, s.lastModified)
+	if err != nil {
+		if s.data != nil || !initial {
+			return false, err
+		}
+		cached, e := readCache(s.url)
+		if e != nil {
+			return false, err
+		}
+		log.Println(s.url, err, "using the cached list")
+		s.data, s.cached, s.updated = cached, true, time.Now()
+		return true, nil
+	}
+	if data == nil {
+		return false, nil
+	}
+	changed := s.cached || !bytes.Equal(data, s.data)
+	s.data, s.etag, s.lastModified, s.cached = data, etag, lastModified, false
+	writeCache(s.url, data)
+	if changed {
+		s.updated = time.Now()
+	}
+	return changed, nil
+}
+
+func (s *source) add(d *DomainFilter) Report {
+	rep := Report{Source: s.src, Format: s.format, Cached: s.cached}
+	switch {
+	case s.path != "":
+		rep.Err = d.addLocal(s.path, s.format, &rep)
+	case s.data != nil:
+		rep.Err = d.addList(bytes.NewReader(s.data), s.format, &rep)
+	default:
+		rep.Err = s.err
+	}
+	if rep.Err != nil {
+		log.Println(s.src, rep.Err)
+	}
+	log.Printf("Domain filter: %s: %d accepted, %d rejected", s.src, rep.Accepted, rep.Rejected)
+	return rep
+}
+
+// rebuild checks the sources of list, and swaps in a filter built of them
+// if one changed or force is set. The filter in use is kept if a source
+// fails, the first filter is swapped in anyway. It is called with lock held.
+func rebuild(list []string, force bool) error {
+	old, _ := current.Load().(*DomainFilter)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	now := time.Now()
+	changed := force || pending || old == nil || strings.Join(list, "\n") != builtList
+	var failed error
+	defer func() { setStatus(list, now, failed) }()
+	keep := map[string]*source{}
+	for _, src := range list {
+		s := sources[src]
+		if s == nil {
+			s = newSource(src)
+		}
+		keep[src] = s
+		c, err := s.check(ctx, old == nil)
+		s.checked, s.err, s.report.Err = now, err, err
+		if err != nil {
+			if failed == nil {
+				failed = fmt.Errorf("%s: %w", src, err)
+			}
+		}
+		changed = changed || c
+	}
+	sources = keep
+	if failed != nil && old != nil {
+		pending = pending || changed
+		log.Println("Domain filter: keeping the filter in use,", failed)
+		return failed
+	}
+	if !changed {
+		return nil
+	}
+
+	d := newFilter()
+	for _, src := range list {
+		s := sources[src]
+		s.report = s.add(d)
+		if s.report.Err != nil && failed == nil {
+			failed = fmt.Errorf("%s: %w", src, s.report.Err)
+		}
+	}
+	if failed != nil && old != nil {
+		pending = true
+		log.Println("Domain filter: keeping the filter in use,", failed)
+		return failed
+	}
+	st := d.Stats()
+	log.Printf("Domain filter: %d domains in %s, ~%d KiB", st.Domains, st.Backend, st.Memory>>10)
+	current.Store(d)
+	builtList, built, pending = strings.Join(list, "\n"), now, false
+	return nil
+}
+
+// SetDomainList replaces the default blocklist URLs, applied by the next
+// UpgradeFilter.
+func SetDomainList(list []string) {
+	lock.Lock()
+	defer lock.Unlock()
+	domainList = append([]string{}, list...)
+}
+
+func getFilter() *DomainFilter {
+	if d, ok := current.Load().(*DomainFilter); ok {
+		return d
+	}
+	filterInit.Do(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if current.Load() == nil {
+			rebuild(domainList, true)
+			watchLocal(domainList)
+		}
+	})
+	return current.Load().(*DomainFilter)
+}
+
+func CheckDomain(hostname []byte) bool {
+	return getFilter().Lookup(hostname)
+}
+
+// UpgradeFilter rebuilds the filter from the lists, keeping the one in use
+// if a list fails. Nothing is done before the filter is first used.
+func UpgradeFilter() error {
+	lock.Lock()
+	defer lock.Unlock()
+	if current.Load() == nil {
+		return nil
+	}
+	watchLocal(domainList)
+	return rebuild(domainList, true)
+}
+
+// refresh rebuilds the filter if a remote list changed.
+func refresh() {
+	lock.Lock()
+	defer lock.Unlock()
+	if current.Load() != nil {
+		rebuild(domainList, false)
+	}
+}
+
+// AddFilter adds the blocklists to the filter and keeps them for
+// UpgradeFilter. Nothing is added if a list fails.
+func AddFilter(List []string) ([]Report, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	list := append([]string{}, domainList...)
+	for _, v := range List {
+		if !contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	err := rebuild(list, true)
+	reports := make([]Report, 0, len(List))
+	for _, v := range List {
+		if s := sources[v]; s != nil {
+			reports = append(reports, s.report)
+		}
+	}
+	if err != nil {
+		return reports, err
+	}
+	domainList = list
+	watchLocal(domainList)
+	return reports, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// StartRefresh checks the remote lists every interval in background,
+// rebuilding the filter when one changed. Calling it again changes the
+// interval, 0 stops it.
+func StartRefresh(interval time.Duration) {
+	tickLock.Lock()
+	defer tickLock.Unlock()
+	refreshInterval = interval
+	if ticker != nil {
+		if interval > 0 {
+			ticker.Reset(interval)
+			return
+		}
+		ticker.Stop()
+		close(stopRefresh)
+		ticker, stopRefresh = nil, nil
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+	t, stop := time.NewTicker(interval), make(chan struct{})
+	ticker, stopRefresh = t, stop
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				refresh()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// SourceStatus is the last check of a list.
+type SourceStatus struct {
+	// Report is of the filter in use, Err of the last check
+	Report
+	Checked time.Time
+	// Updated is when the remote list last changed
+	Updated time.Time
+}
+
+// Status is the state of the default filter.
+type Status struct {
+	Stats
+	// Built is when the filter in use was built, zero before its first use
+	Built   time.Time
+	Checked time.Time
+	// Err is the first source failed in the last check
+	Err      error
+	Interval time.Duration
+	Sources  []SourceStatus
+}
+
+// setStatus copies the last check, called by rebuild.
+func setStatus(list []string, now time.Time, err error) {
+	st := Status{Built: built, Checked: now, Err: err}
+	for _, src := range list {
+		s := sources[src]
+		st.Sources = append(st.Sources, SourceStatus{
+			Report:  s.report,
+			Checked: s.checked,
+			Updated: s.updated,
+		})
+	}
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	status = st
+}
+
+// GetStatus returns the state of the default filter, never waiting for a
+// rebuild.
+func GetStatus() Status {
+	tickLock.Lock()
+	interval := refreshInterval
+	tickLock.Unlock()
+	statusLock.Lock()
+	st := status
+	statusLock.Unlock()
+	st.Interval = interval
+	if d, ok := current.Load().(*DomainFilter); ok {
+		st.Stats = d.Stats()
+	}
+	return st
+}
@@ -0,0 +1,80 @@
+package domainfilter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// list serves a blocklist with an ETag, or 500 while failing.
+type list struct {
+	lock    sync.Mutex
+	body    string
+	failing bool
+}
+
+func (l *list) set(body string, failing bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.body, l.failing = body, failing
+}
+
+func (l *list) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf("%q", l.body)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, l.body)
+}
+
+// resetDefault starts the default filter over with the lists.
+func resetDefault(list ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	current, filterInit = atomic.Value{}, sync.Once{}
+	domainList, sources, builtList, pending = list, map[string]*source{}, "", false
+	SetCacheDir("")
+}
+
+func TestRefreshKeepsChangesOnFailure(t *testing.T) {
+	a, b := &list{body: "old.com\n"}, &list{body: "b.com\n"}
+	sa, sb := httptest.NewServer(a), httptest.NewServer(b)
+	defer sa.Close()
+	defer sb.Close()
+	resetDefault(sa.URL, sb.URL)
+	if !CheckDomain([]byte("old.com")) {
+		t.Fatal("old.com not blocked")
+	}
+
+	// a changes while b fails, the filter in use is kept
+	a.set("new.com\n", false)
+	b.set("b.com\n", true)
+	refresh()
+	if CheckDomain([]byte("new.com")) || !CheckDomain([]byte("old.com")) {
+		t.Fatal("filter swapped while a list failed")
+	}
+	if GetStatus().Err == nil {
+		t.Fatal("failure not reported")
+	}
+
+	// b recovers unchanged, a answers 304, the change is still built in
+	b.set("b.com\n", false)
+	refresh()
+	if !CheckDomain([]byte("new.com")) || CheckDomain([]byte("old.com")) {
+		t.Fatal("change of a lost after b recovered")
+	}
+	if err := GetStatus().Err; err != nil {
+		t.Fatal(err)
+	}
+}
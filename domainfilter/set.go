@@ -0,0 +1,160 @@
+package domainfilter
+
+import (
+	"log"
+	"sync"
+
+	cuckoo "github.com/seiflotfy/cuckoofilter"
+)
+
+// The backends of the domain set.
+const (
+	// BackendHash matches exactly with a hash set.
+	BackendHash = "hash"
+	// BackendCuckoo is smaller but has false positives, and drops the
+	// domains inserted once full.
+	BackendCuckoo = "cuckoo"
+)
+
+const cuckooCapacity = 1000000
+
+// domainSet holds the listed domains.
+type domainSet interface {
+	insert(domain []byte)
+	lookup(domain []byte) bool
+	reset()
+	len() int
+	// memory estimates the bytes used
+	memory() int64
+}
+
+type cuckooSet struct {
+	lock   sync.RWMutex
+	filter *cuckoo.Filter
+	// full is set once an insert has been dropped
+	full bool
+}
+
+func newCuckooSet() *cuckooSet {
+	return &cuckooSet{filter: cuckoo.NewFilter(cuckooCapacity)}
+}
+
+func (s *cuckooSet) insert(domain []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.filter.Lookup(domain) {
+		return
+	}
+	if !s.filter.Insert(domain) && !s.full {
+		s.full = true
+		log.Println("Domain filter is full, domains are dropped")
+	}
+}
+
+func (s *cuckooSet) lookup(domain []byte) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.filter.Lookup(domain)
+}
+
+func (s *cuckooSet) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.filter.Reset()
+	s.full = false
+}
+
+func (s *cuckooSet) len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return int(s.filter.Count())
+}
+
+// memory is one byte per fingerprint, the buckets rounded up to a power
+// of two.
+func (s *cuckooSet) memory() int64 {
+	n := int64(1)
+	for n < cuckooCapacity {
+		n <<= 1
+	}
+	return n
+}
+
+// hashOverhead estimates the bytes of a map entry besides the domain:
+// the string header, the bucket slack and the allocation rounding.
+// BenchmarkMemory measures 57 for a million domains of 23 bytes with
+// Go 1.27 on amd64:
+//
+//	go test -run - -bench Memory ./domainfilter
+const hashOverhead = 57
+
+type hashSet struct {
+	lock  sync.RWMutex
+	m     map[string]struct{}
+	bytes int64
+	// pre rejects most misses before hashing, unless it has dropped some
+	pre *cuckooSet
+}
+
+func newHashSet(precheck bool) *hashSet {
+	s := &hashSet{m: make(map[string]struct{})}
+	if precheck {
+		s.pre = newCuckooSet()
+	}
+	return s
+}
+
+func (s *hashSet) insert(domain []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.m[string(domain)]; ok {
+		return
+	}
+	s.m[string(domain)] = struct{}{}
+	s.bytes += int64(len(domain))
+	if s.pre != nil {
+		s.pre.insert(domain)
+	}
+}
+
+func (s *hashSet) lookup(domain []byte) bool {
+	if s.pre != nil && !s.pre.lookup(domain) && !s.preFull() {
+		return false
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, ok := s.m[string(domain)]
+	return ok
+}
+
+func (s *hashSet) preFull() bool {
+	s.pre.lock.RLock()
+	defer s.pre.lock.RUnlock()
+	return s.pre.full
+}
+
+func (s *hashSet) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m = make(map[string]struct{})
+	s.bytes = 0
+	if s.pre != nil {
+		s.pre.reset()
+	}
+}
+
+func (s *hashSet) len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.m)
+}
+
+func (s *hashSet) memory() int64 {
+	s.lock.RLock()
+	n := s.bytes + int64(len(s.m))*hashOverhead
+	s.lock.RUnlock()
+	if s.pre != nil {
+		n += s.pre.memory()
+	}
+	return n
+}
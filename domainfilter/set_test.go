@@ -0,0 +1,73 @@
+package domainfilter
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+const benchDomains = 1000000
+
+var benchSets = []struct {
+	name string
+	new  func() domainSet
+}{
+	{"hash", func() domainSet { return newHashSet(false) }},
+	{"cuckoo", func() domainSet { return newCuckooSet() }},
+	{"hash+precheck", func() domainSet { return newHashSet(true) }},
+}
+
+func benchDomain(i int) []byte {
+	return []byte("host-" + strconv.Itoa(i) + ".example.com")
+}
+
+func fill(s domainSet, n int) {
+	for i := 0; i < n; i++ {
+		s.insert(benchDomain(i))
+	}
+}
+
+// BenchmarkLookup looks up listed and unlisted domains alike, in parallel
+// as the connections do.
+func BenchmarkLookup(b *testing.B) {
+	for _, bs := range benchSets {
+		b.Run(bs.name, func(b *testing.B) {
+			s := bs.new()
+			fill(s, benchDomains)
+			queries := make([][]byte, 1024)
+			for i := range queries {
+				queries[i] = benchDomain(i * benchDomains / len(queries) * 2)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					s.lookup(queries[i%len(queries)])
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMemory reports the heap used per domain against the estimate
+// of memory, which hashOverhead is taken from.
+func BenchmarkMemory(b *testing.B) {
+	for _, bs := range benchSets {
+		b.Run(bs.name, func(b *testing.B) {
+			var heap, estimate float64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				s := bs.new()
+				fill(s, benchDomains)
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				heap += float64(after.HeapAlloc - before.HeapAlloc)
+				estimate += float64(s.memory())
+				runtime.KeepAlive(s)
+			}
+			b.ReportMetric(heap/float64(b.N)/benchDomains, "B/domain")
+			b.ReportMetric(estimate/float64(b.N)/benchDomains, "estimated-B/domain")
+		})
+	}
+}
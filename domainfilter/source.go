@@ -0,0 +1,321 @@
+package domainfilter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// client gives up early on an unreachable remote, falling back to the
+// cache, but not on a slow download.
+var client = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// SetCacheDir sets the directory of the cached remote lists, read when a
+// remote is unreachable. Empty disables the cache.
+func SetCacheDir(dir string) {
+	optLock.Lock()
+	defer optLock.Unlock()
+	cacheDir = dir
+}
+
+func getCacheDir() string {
+	optLock.RLock()
+	defer optLock.RUnlock()
+	return cacheDir
+}
+
+func cachePath(dir, src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".list")
+}
+
+// localPath returns the path of a "file:///abs/path" or "file:rel/path"
+// source.
+func localPath(u *url.URL) (string, error) {
+	if u.Opaque != "" {
+		return u.Opaque, nil
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("file URL of host %q", u.Host)
+	}
+	if u.Path == "" {
+		return "", errors.New("empty file URL")
+	}
+	return u.Path, nil
+}
+
+// localSource returns the path of src if it is a local list.
+func localSource(src string) (string, bool) {
+	s, _, err := ParseSource(src)
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	path, err := localPath(u)
+	return path, err == nil
+}
+
+// maxList is the largest remote list.
+const maxList = 256 << 20
+
+// fetch gets a remote list, nil if it isn't modified since the etag or
+// lastModified given, and returns them for the next fetch.
+func fetch(ctx context.Context, src, etag, lastModified string) ([]byte, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if etag != "" || lastModified != "" {
+			return nil, etag, lastModified, nil
+		}
+		fallthrough
+	default:
+		return nil, "", "", fmt.Errorf("status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxList+1))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if len(data) > maxList {
+		return nil, "", "", errors.New("list too large")
+	}
+	return data, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), nil
+}
+
+func readCache(src string) ([]byte, error) {
+	dir := getCacheDir()
+	if dir == "" {
+		return nil, errors.New("no cache")
+	}
+	return os.ReadFile(cachePath(dir, src))
+}
+
+// writeCache saves a remote list, replacing the cached one atomically.
+func writeCache(src string, data []byte) {
+	dir := getCacheDir()
+	if dir == "" {
+		return
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		log.Println("Blocklist cache:", err)
+		return
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		log.Println("Blocklist cache:", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cachePath(dir, src))
+	}
+	if err != nil {
+		log.Println("Blocklist cache:", err)
+	}
+}
+
+// addLocal adds a file, or the files of a directory but the hidden ones.
+func (d *DomainFilter) addLocal(path string, f Format, rep *Report) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return d.addFile(path, f, rep)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := d.addFile(filepath.Join(path, e.Name()), f, rep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DomainFilter) addFile(path string, f Format, rep *Report) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return d.addList(file, f, rep)
+}
+
+// watchDelay gathers the events of an edit into one rebuild.
+const watchDelay = time.Second
+
+// watcher rebuilds the filter when a local list changes. It watches the
+// directories of the files, to keep up with the files replaced by a
+// rename.
+type watcher struct {
+	w     *fsnotify.Watcher
+	lock  sync.Mutex
+	dirs  map[string]*watchedDir
+	timer *time.Timer
+}
+
+type watchedDir struct {
+	// all is set for a list directory, files for the lists in it
+	all   bool
+	files map[string]bool
+}
+
+var (
+	watchLock sync.Mutex
+	watch     *watcher
+)
+
+// watchLocal watches the local lists of list, and no other.
+func watchLocal(list []string) {
+	dirs := map[string]*watchedDir{}
+	for _, src := range list {
+		path, ok := localSource(src)
+		if !ok {
+			continue
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		dir, name := filepath.Dir(path), filepath.Base(path)
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			dir, name = path, ""
+		}
+		wd := dirs[dir]
+		if wd == nil {
+			wd = &watchedDir{files: map[string]bool{}}
+			dirs[dir] = wd
+		}
+		if name == "" {
+			wd.all = true
+		} else {
+			wd.files[name] = true
+		}
+	}
+
+	watchLock.Lock()
+	defer watchLock.Unlock()
+	if watch == nil {
+		if len(dirs) == 0 {
+			return
+		}
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Println("Blocklist watch:", err)
+			return
+		}
+		watch = &watcher{w: w, dirs: map[string]*watchedDir{}}
+		go watch.run()
+	}
+	watch.set(dirs)
+}
+
+func (w *watcher) set(dirs map[string]*watchedDir) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for dir := range w.dirs {
+		if dirs[dir] == nil {
+			w.w.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if w.dirs[dir] == nil {
+			if err := w.w.Add(dir); err != nil {
+				log.Println("Blocklist watch:", dir, err)
+			}
+		}
+	}
+	w.dirs = dirs
+}
+
+func (w *watcher) match(path string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	wd := w.dirs[filepath.Dir(path)]
+	if wd == nil {
+		return false
+	}
+	name := filepath.Base(path)
+	return wd.files[name] || wd.all && !strings.HasPrefix(name, ".")
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case ev, ok := <-w.w.Events:
+			if !ok {
+				return
+			}
+			if ev.Op != fsnotify.Chmod && w.match(ev.Name) {
+				w.changed()
+			}
+		case err, ok := <-w.w.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Blocklist watch:", err)
+		}
+	}
+}
+
+func (w *watcher) changed() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.timer != nil {
+		w.timer.Reset(watchDelay)
+		return
+	}
+	w.timer = time.AfterFunc(watchDelay, func() {
+		log.Println("Domain filter: local lists changed")
+		UpgradeFilter()
+	})
+}
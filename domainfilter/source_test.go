@@ -0,0 +1,135 @@
+package domainfilter
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitBlocked waits for the watcher to rebuild the filter.
+func waitBlocked(t *testing.T, domain string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if CheckDomain([]byte(domain)) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("%s not blocked after the edit", domain)
+}
+
+// replace writes a file by a rename, as editors do.
+func replace(t *testing.T, path, body string) {
+	t.Helper()
+	tmp := filepath.Join(filepath.Dir(path), ".edit")
+	if err := os.WriteFile(tmp, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchLocal(t *testing.T) {
+	dir, listDir := t.TempDir(), t.TempDir()
+	file := filepath.Join(dir, "block.txt")
+	if err := os.WriteFile(file, []byte("old.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(listDir, "a.txt"), []byte("a.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resetDefault("file://"+file, "file://"+listDir)
+	defer func() {
+		resetDefault()
+		watchLocal(nil)
+	}()
+	if !CheckDomain([]byte("old.com")) || !CheckDomain([]byte("a.com")) {
+		t.Fatal("local lists not blocked")
+	}
+
+	// a file edited in place
+	if err := os.WriteFile(file, []byte("old.com\nnew.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitBlocked(t, "new.com")
+
+	// a file replaced by a rename
+	replace(t, file, "renamed.com\n")
+	waitBlocked(t, "renamed.com")
+	if CheckDomain([]byte("old.com")) {
+		t.Fatal("old.com blocked after the rename")
+	}
+
+	// a file added to a list directory, the hidden ones are skipped
+	if err := os.WriteFile(filepath.Join(listDir, ".b.txt"), []byte("hidden.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(listDir, "b.txt"), []byte("b.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitBlocked(t, "b.com")
+	if CheckDomain([]byte("hidden.com")) {
+		t.Fatal("hidden file added")
+	}
+	if err := GetStatus().Err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheFallback(t *testing.T) {
+	l := &list{body: "cached.com\n"}
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+	dir := filepath.Join(t.TempDir(), "cache")
+	resetDefault(srv.URL)
+	SetCacheDir(dir)
+	if !CheckDomain([]byte("cached.com")) {
+		t.Fatal("cached.com not blocked")
+	}
+	data, err := os.ReadFile(cachePath(dir, srv.URL))
+	if err != nil || string(data) != l.body {
+		t.Fatalf("cache %q %v", data, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("cache dir %v %v, want only the list", entries, err)
+	}
+
+	// a new start with the remote down uses the cached copy
+	l.set("other.com\n", true)
+	resetDefault(srv.URL)
+	SetCacheDir(dir)
+	if !CheckDomain([]byte("cached.com")) {
+		t.Fatal("cached list not used")
+	}
+	if st := GetStatus(); len(st.Sources) != 1 || !st.Sources[0].Cached {
+		t.Fatalf("status %+v, want the cached list", st)
+	}
+
+	// the remote back up replaces the cached copy
+	l.set("other.com\n", false)
+	refresh()
+	if !CheckDomain([]byte("other.com")) || CheckDomain([]byte("cached.com")) {
+		t.Fatal("remote list not swapped in")
+	}
+	if st := GetStatus(); st.Sources[0].Cached {
+		t.Fatal("still reported cached")
+	}
+	data, err = os.ReadFile(cachePath(dir, srv.URL))
+	if err != nil || string(data) != "other.com\n" {
+		t.Fatalf("cache %q %v after the update", data, err)
+	}
+
+	// without a cache the first build fails
+	l.set("other.com\n", true)
+	resetDefault(srv.URL)
+	if CheckDomain([]byte("other.com")) {
+		t.Fatal("blocked without the remote nor a cache")
+	}
+	if GetStatus().Err == nil {
+		t.Fatal("failure not reported")
+	}
+}
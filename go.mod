@@ -1,4 +1,4 @@
-module github.com/BishiNET/ss-server
-
-go 1.18
-
+module github.com/BishiNET/ss-server
+
+go 1.18
+
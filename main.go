@@ -1,19 +1,252 @@
-package main
-
-import (
-	"os"
-	"os/signal"
-	"syscall"
-
-	api "github.com/BishiNET/ss-server/rpcAPI"
-)
-
-func main() {
-	r := api.New("127.0.0.1:50899", "127.0.0.1:6379")
-	defer r.RedisClose()
-	r.FastRestore()
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
-}
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BishiNET/ss-server/config"
+	filter "github.com/BishiNET/ss-server/domainfilter"
+	api "github.com/BishiNET/ss-server/rpcAPI"
+	"github.com/BishiNET/ss-server/server"
+	"github.com/BishiNET/ss-server/store"
+	u "github.com/BishiNET/ss-server/usermap"
+)
+
+var flags struct {
+	Config        string
+	RPC           string
+	REST          string
+	GRPC          string
+	Store         string
+	StorePath     string
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	NodeID        string
+	BindIP        string
+	Blocklists    string
+	NATTimeout    time.Duration
+	FlushInterval time.Duration
+	DrainTimeout  time.Duration
+	SFCapacity    float64
+	SFFPR         float64
+	SFSlot        int
+}
+
+func parseFlags() {
+	flag.StringVar(&flags.Config, "c", "", "config file, YAML or JSON")
+	flag.StringVar(&flags.RPC, "rpc", "", "RPC listen address")
+	flag.StringVar(&flags.REST, "rest", "", "REST API listen address")
+	flag.StringVar(&flags.GRPC, "grpc", "", "gRPC API listen address")
+	flag.StringVar(&flags.Store, "store", "", "user store, redis or file")
+	flag.StringVar(&flags.StorePath, "store-path", "", "JSON file of the file store")
+	flag.StringVar(&flags.RedisAddr, "redis", "", "Redis address")
+	flag.StringVar(&flags.RedisPassword, "redis-password", "", "Redis password")
+	flag.IntVar(&flags.RedisDB, "redis-db", 0, "Redis database")
+	flag.StringVar(&flags.NodeID, "node", "", "cluster node ID, empty disables the sync")
+	flag.StringVar(&flags.BindIP, "bind", "", "IP the user ports listen on")
+	flag.StringVar(&flags.Blocklists, "blocklist", "", "comma-separated blocklist URLs")
+	flag.DurationVar(&flags.NATTimeout, "nat-timeout", 0, "UDP NAT idle timeout")
+	flag.DurationVar(&flags.FlushInterval, "flush-interval", 0, "interval of saving the traffic to the store, 0 disables")
+	flag.DurationVar(&flags.DrainTimeout, "drain-timeout", 0, "how long to wait for the connections on shutdown")
+	flag.Float64Var(&flags.SFCapacity, "sf-capacity", 0, "salt filter capacity, negative disables")
+	flag.Float64Var(&flags.SFFPR, "sf-fpr", 0, "salt filter false positive rate")
+	flag.IntVar(&flags.SFSlot, "sf-slot", 0, "salt filter slots")
+	flag.Parse()
+}
+
+// loadConfig reads the config file and overrides it with the flags.
+func loadConfig() (*config.Config, error) {
+	c, err := config.Load(flags.Config)
+	if err != nil {
+		return nil, err
+	}
+	// flags given explicitly override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "rpc":
+			c.RPC = flags.RPC
+		case "rest":
+			c.REST = flags.REST
+		case "grpc":
+			c.GRPC = flags.GRPC
+		case "store":
+			c.Store.Type = flags.Store
+		case "store-path":
+			c.Store.Path = flags.StorePath
+		case "redis":
+			c.Redis.Addr = flags.RedisAddr
+		case "redis-password":
+			c.Redis.Password = flags.RedisPassword
+		case "redis-db":
+			c.Redis.DB = flags.RedisDB
+		case "node":
+			c.Cluster.NodeID = flags.NodeID
+		case "bind":
+			c.BindIP = flags.BindIP
+		case "blocklist":
+			c.Blocklists = nil
+			for _, v := range strings.Split(flags.Blocklists, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					c.Blocklists = append(c.Blocklists, v)
+				}
+			}
+		case "nat-timeout":
+			c.NATTimeout = config.Duration(flags.NATTimeout)
+		case "flush-interval":
+			c.FlushInterval = config.Duration(flags.FlushInterval)
+		case "drain-timeout":
+			c.DrainTimeout = config.Duration(flags.DrainTimeout)
+		case "sf-capacity":
+			c.SaltFilter.Capacity = flags.SFCapacity
+		case "sf-fpr":
+			c.SaltFilter.FPR = flags.SFFPR
+		case "sf-slot":
+			c.SaltFilter.Slot = flags.SFSlot
+		}
+	})
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var (
+	cfgLock sync.Mutex
+	cfg     *config.Config
+)
+
+// apply applies the settings safe to change live.
+func apply(r *api.UserRpc, c, old *config.Config) {
+	r.SetAuthKeys(c.RPCAuth.Keys, time.Duration(c.RPCAuth.Window))
+	server.SetQuiet(c.Quiet)
+	server.SetNATTimeout(time.Duration(c.NATTimeout))
+	r.StartFlusher(time.Duration(c.FlushInterval))
+	r.SetDefaultRateLimit(c.RateLimit.Up, c.RateLimit.Down)
+	filter.SetCacheDir(c.BlocklistCache)
+	filter.StartRefresh(time.Duration(c.BlocklistRefresh))
+	if old != nil && strings.Join(c.Blocklists, "\n") != strings.Join(old.Blocklists, "\n") {
+		filter.SetDomainList(c.Blocklists)
+		go filter.UpgradeFilter()
+	}
+}
+
+// reload re-reads the config, leaving the running one as is on errors.
+func reload(r *api.UserRpc) ([]string, []string, error) {
+	c, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfgLock.Lock()
+	defer cfgLock.Unlock()
+	live, restart := c.Changes(cfg)
+	apply(r, c, cfg)
+	// the settings requiring a restart stay in effect until then
+	c.RPC, c.REST, c.GRPC = cfg.RPC, cfg.REST, cfg.GRPC
+	c.Store, c.Redis, c.Cluster = cfg.Store, cfg.Redis, cfg.Cluster
+	c.BindIP, c.SaltFilter, c.DomainSet = cfg.BindIP, cfg.SaltFilter, cfg.DomainSet
+	cfg = c
+	return live, restart, nil
+}
+
+func openStore(c *config.Config) (store.Store, error) {
+	if c.Store.Type == "file" {
+		return store.NewFile(c.Store.Path)
+	}
+	st, err := store.NewRedis(c.Redis.Addr, c.Redis.Password, c.Redis.DB, c.Redis.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if c.Cluster.NodeID != "" {
+		if err := st.SetNode(c.Cluster.NodeID); err != nil {
+			st.Close()
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func drainTimeout() time.Duration {
+	cfgLock.Lock()
+	defer cfgLock.Unlock()
+	return time.Duration(cfg.DrainTimeout)
+}
+
+func main() {
+	parseFlags()
+	c, err := loadConfig()
+	if err != nil {
+		log.Fatalln("Invalid config:", err)
+	}
+	cfg = c
+	if len(c.RPCAuth.Keys) == 0 {
+		log.Println("RPC authentication is disabled, keep the control plane private")
+	}
+	u.SetBindIP(c.BindIP)
+	filter.SetDomainList(c.Blocklists)
+	filter.SetCacheDir(c.BlocklistCache)
+	filter.SetBackend(c.DomainSet.Backend, c.DomainSet.Precheck)
+	if c.SaltFilter != (config.SaltFilter{}) {
+		server.SetSaltFilter(c.SaltFilter.Capacity, c.SaltFilter.FPR, c.SaltFilter.Slot)
+	}
+
+	st, err := openStore(c)
+	if err != nil {
+		log.Fatalln("Open store:", err)
+	}
+	r := api.New(c.RPC, st)
+	defer r.StoreClose()
+	if c.Cluster.NodeID != "" {
+		if err := r.JoinCluster(st.(store.Cluster), c.Cluster.Ports); err != nil {
+			log.Fatalln("Join cluster:", err)
+		}
+	}
+	apply(r, c, nil)
+	if c.REST != "" {
+		if err := r.ServeREST(c.REST); err != nil {
+			log.Fatalln("REST listen error:", err)
+		}
+	}
+	if c.GRPC != "" {
+		if err := r.ServeGRPC(c.GRPC); err != nil {
+			log.Fatalln("gRPC listen error:", err)
+		}
+	}
+	r.SetReloader(func() ([]string, []string, error) {
+		return reload(r)
+	})
+	r.FastRestore()
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			live, restart, err := reload(r)
+			if err != nil {
+				log.Println("Reload config:", err)
+				continue
+			}
+			log.Println("Reload config, applied:", live, "restart required:", restart)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	log.Println("Shutting down, signal again to force exit")
+	go func() {
+		<-sigCh
+		log.Println("Forced exit")
+		os.Exit(1)
+	}()
+
+	server.Shutdown(drainTimeout())
+	r.Flush()
+	r.Close()
+}
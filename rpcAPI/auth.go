@@ -0,0 +1,85 @@
+package rpcapi
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+)
+
+var (
+	errNotReady  = errors.New("keys not set yet")
+	errUnknownID = errors.New("unknown access ID")
+	errExpired   = errors.New("timestamp out of window")
+	errReplayed  = errors.New("replayed call")
+)
+
+// authState holds the server-side keys. No keys disables authentication,
+// no calls are accepted before they are set.
+type authState struct {
+	lock   sync.Mutex
+	keys   map[string]string
+	window time.Duration
+	// seen maps the signatures within the window to their timestamps
+	seen map[string]int64
+}
+
+// SetAuthKeys sets the secrets by access ID and how far the timestamp of a
+// call may be off.
+func (r *UserRpc) SetAuthKeys(keys map[string]string, window time.Duration) {
+	r.auth.lock.Lock()
+	defer r.auth.lock.Unlock()
+	r.auth.keys = make(map[string]string, len(keys))
+	for k, v := range keys {
+		r.auth.keys[k] = v
+	}
+	r.auth.window = window
+	if r.auth.seen == nil {
+		r.auth.seen = make(map[string]int64)
+	}
+}
+
+// authorize checks the signature of a call of method, e.g. "UserRpc.AddUser".
+func (r *UserRpc) authorize(method string, args R.Signed) error {
+	err := r.checkAuth(method, args)
+	if err != nil {
+		log.Println("Reject "+method, args.Credentials().AccessID, err)
+	}
+	return err
+}
+
+func (r *UserRpc) checkAuth(method string, args R.Signed) error {
+	r.auth.lock.Lock()
+	defer r.auth.lock.Unlock()
+	if r.auth.keys == nil {
+		return errNotReady
+	}
+	if len(r.auth.keys) == 0 {
+		return nil
+	}
+	a := args.Credentials()
+	secret, ok := r.auth.keys[a.AccessID]
+	if !ok {
+		return errUnknownID
+	}
+	now := time.Now().Unix()
+	window := int64(r.auth.window / time.Second)
+	if a.Timestamp < now-window || a.Timestamp > now+window {
+		return errExpired
+	}
+	if err := R.Verify(method, args, secret); err != nil {
+		return err
+	}
+	for k, ts := range r.auth.seen {
+		if ts < now-window {
+			delete(r.auth.seen, k)
+		}
+	}
+	if _, ok := r.auth.seen[a.Sign]; ok {
+		return errReplayed
+	}
+	r.auth.seen[a.Sign] = a.Timestamp
+	return nil
+}
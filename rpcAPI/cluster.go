@@ -0,0 +1,126 @@
+package rpcapi
+
+import (
+	"fmt"
+	"log"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/store"
+)
+
+// JoinCluster fans the changes made through this node out to the nodes
+// sharing the store and applies theirs. ports overrides the port of the
+// users by name on this node. It must be called before FastRestore.
+func (r *UserRpc) JoinCluster(c store.Cluster, ports map[string]string) error {
+	r.cluster, r.ports = c, ports
+	return c.Subscribe(r.applyEvent)
+}
+
+// userPort is the port a user listens on at this node.
+func (r *UserRpc) userPort(name, port string) string {
+	if p, ok := r.ports[name]; ok {
+		return p
+	}
+	return port
+}
+
+func (r *UserRpc) publish(op, name string) {
+	if r.cluster == nil {
+		return
+	}
+	if err := r.cluster.Publish(op, name); err != nil {
+		log.Println("Publish "+op+" "+name, err)
+	}
+}
+
+// applyEvent applies a change made on another node to the running users.
+func (r *UserRpc) applyEvent(ev store.Event) {
+	defer r.locks.hold(ev.Name)()
+	var err error
+	switch ev.Op {
+	case store.EventAdd, store.EventStart:
+		err = r.startStored(ev.Name)
+	case store.EventModify:
+		if !r.Users.Exists(ev.Name) {
+			break
+		}
+		var su *store.User
+		if su, err = r.st.Get(ev.Name); err == nil {
+			err = r.replaceUser(ev.Name, su.Cipher, su.Password, su.Port)
+			r.scheduleExpiry(ev.Name, su.ExpireAt)
+		}
+	case store.EventUpdate:
+		if !r.Users.Exists(ev.Name) {
+			break
+		}
+		var su *store.User
+		if su, err = r.st.Get(ev.Name); err == nil {
+			r.Users.SetUserQuota(ev.Name, su.Quota)
+			r.pullRemote(ev.Name)
+			upLimit, downLimit := r.rateLimit(su.UpLimit, su.DownLimit)
+			r.Users.SetUserRateLimit(ev.Name, upLimit, downLimit)
+			r.scheduleExpiry(ev.Name, su.ExpireAt)
+			r.scheduleReset(ev.Name, su.NextReset)
+		}
+	case store.EventStop, store.EventExpire:
+		if !r.Users.Exists(ev.Name) {
+			break
+		}
+		if err = r.flushUser(ev.Name); err != nil {
+			log.Println("Flush "+ev.Name, err)
+		}
+		r.stopLocal(ev.Name)
+	case store.EventDelete:
+		if !r.Users.Exists(ev.Name) {
+			break
+		}
+		r.stopLocal(ev.Name)
+	case store.EventReset:
+		// the store has been reset already
+		if ev.Name == "" {
+			r.resetLocal()
+		} else if r.Users.Exists(ev.Name) {
+			r.zeroFlushed(ev.Name)
+		}
+	default:
+		err = fmt.Errorf("unknown event %q", ev.Op)
+	}
+	log.Println("Event "+ev.Op+" "+ev.Name+" from "+ev.Node, err)
+}
+
+// pullRemote counts the traffic of a user on the other nodes, as of their
+// last flush, against its quota here.
+func (r *UserRpc) pullRemote(name string) {
+	if r.cluster == nil || r.Users.GetUserQuota(name) == 0 {
+		return
+	}
+	others, err := r.cluster.Traffic(name)
+	if err != nil {
+		log.Println("Traffic "+name, err)
+		return
+	}
+	r.Users.SetUserRemote(name, others.Traffic)
+}
+
+// total adds the counters of the other nodes to the local ones.
+func (r *UserRpc) total(name string, c counters) R.SingleTrafficReply {
+	c = r.totalCounters(name, c)
+	return trafficReply(c.traffic, c.time, c.detail)
+}
+
+func (r *UserRpc) totalCounters(name string, c counters) counters {
+	if r.cluster != nil {
+		others, err := r.cluster.Traffic(name)
+		if err != nil {
+			log.Println("Traffic "+name, err)
+		} else {
+			c.traffic += others.Traffic
+			c.time += others.Time
+			c.detail.TCPUpload += others.TCPUp
+			c.detail.TCPDownload += others.TCPDown
+			c.detail.UDPUpload += others.UDPUp
+			c.detail.UDPDownload += others.UDPDown
+		}
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package rpcapi
+
+import (
+	"testing"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/store"
+)
+
+// fakeCluster reports the traffic of the other nodes by user.
+type fakeCluster struct {
+	traffic map[string]uint64
+}
+
+func (c *fakeCluster) Node() string                        { return "test" }
+func (c *fakeCluster) Publish(op, name string) error       { return nil }
+func (c *fakeCluster) Subscribe(f func(store.Event)) error { return nil }
+func (c *fakeCluster) Traffic(name string) (store.Counters, error) {
+	return store.Counters{Traffic: c.traffic[name]}, nil
+}
+
+func TestQuotaCountsOtherNodes(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	others := &fakeCluster{traffic: map[string]uint64{}}
+	r.cluster = others
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t), Quota: 1000}
+	if err := r.addUser(args); err != nil {
+		t.Fatal(err)
+	}
+	r.Users.SetUser("a", 600, 0)
+	r.Flush()
+	if r.Users.Get("a").Exceeded() {
+		t.Fatal("exceeded on the local traffic alone")
+	}
+	others.traffic["a"] = 500
+	r.Flush()
+	if !r.Users.Get("a").Exceeded() {
+		t.Fatal("traffic of the other nodes not counted")
+	}
+	if err := r.resetFlushed("a"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Users.Get("a").Exceeded() {
+		t.Fatal("still exceeded after a reset")
+	}
+}
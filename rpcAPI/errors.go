@@ -0,0 +1,97 @@
+package rpcapi
+
+import (
+	"encoding/base64"
+	"errors"
+	"net"
+	"syscall"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/server"
+	"github.com/BishiNET/ss-server/store"
+)
+
+var (
+	errUserExists    = errors.New("user has already existed")
+	errUserNonExists = errors.New("user doesn't exist")
+	errNotModified   = errors.New("nothing is modfied")
+	errUserExpired   = errors.New("user has expired")
+	errBadExpiry     = errors.New("invalid expiry")
+
+	errReloadNotSupported = errors.New("reload isn't supported")
+)
+
+// storeError marks the failures of the user store.
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string { return "store: " + e.err.Error() }
+func (e *storeError) Unwrap() error { return e.err }
+
+func storeErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, store.ErrNotFound):
+		return errUserNonExists
+	}
+	return &storeError{err}
+}
+
+// filterError marks a blocklist failed, the filter in use is kept.
+type filterError struct {
+	err error
+}
+
+func (e *filterError) Error() string { return "filter: " + e.err.Error() }
+func (e *filterError) Unwrap() error { return e.err }
+
+// errCode tells the error code of err.
+func errCode(err error) int {
+	var (
+		serr *storeError
+		ferr *filterError
+		oerr *net.OpError
+		kerr server.KeySizeError
+		berr base64.CorruptInputError
+	)
+	switch {
+	case err == nil:
+		return NO_ERROR
+	case errors.Is(err, errUserExists):
+		return USER_EXISTS
+	case errors.Is(err, errUserNonExists):
+		return USER_NON_EXISTS
+	case errors.Is(err, errUserExpired):
+		return USER_EXPIRED
+	case errors.Is(err, errNotReady), errors.Is(err, errUnknownID), errors.Is(err, errExpired),
+		errors.Is(err, errReplayed), errors.Is(err, R.ErrBadSign):
+		return AUTH_ERROR
+	case errors.As(err, &serr):
+		return REDIS_ERROR
+	case errors.As(err, &ferr):
+		return FILTER_ERROR
+	case errors.Is(err, server.ErrCipherNotSupported), errors.Is(err, server.ErrIdentityNotSupported):
+		return INVALID_CIPHER
+	case errors.As(err, &kerr), errors.As(err, &berr):
+		return INVALID_PASSWORD
+	case errors.Is(err, syscall.EADDRINUSE), errors.Is(err, server.ErrIdentityMismatch),
+		errors.Is(err, server.ErrDuplicateKey):
+		return PORT_IN_USE
+	case errors.As(err, &oerr) && oerr.Op == "listen":
+		return LISTEN_ERROR
+	}
+	return PARAMS_ERROR
+}
+
+// callResult fills reply with the outcome of a call. The error is not
+// returned, since net/rpc drops the reply of a failed call; callers check
+// the ErrCode instead.
+func callResult(reply *R.CallReply, err error) error {
+	*reply = R.CallReply{ErrCode: errCode(err)}
+	if err != nil {
+		reply.ErrReason = err.Error()
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package rpcapi
+
+import (
+	"log"
+	"time"
+
+	"github.com/BishiNET/ss-server/store"
+)
+
+func expired(rec store.Record, now time.Time) bool {
+	return rec.Expired || (rec.ExpireAt > 0 && rec.ExpireAt <= now.Unix())
+}
+
+// scheduleExpiry stops the user at expireAt, 0 cancels it.
+func (r *UserRpc) scheduleExpiry(name string, expireAt int64) {
+	r.expiry.schedule(name, expireAt, r.expire)
+}
+
+// expire stops a user whose expiry has passed and marks it expired.
+func (r *UserRpc) expire(name string) {
+	defer r.locks.hold(name)()
+	su, err := r.st.Get(name)
+	if err != nil {
+		log.Println("Expire "+name, err)
+		return
+	}
+	// pushed back by another node
+	if !expired(su.Record, time.Now()) {
+		if r.Users.Exists(name) {
+			r.scheduleExpiry(name, su.ExpireAt)
+		}
+		return
+	}
+	if r.Users.Exists(name) {
+		if err := r.flushUser(name); err != nil {
+			log.Println("Flush "+name, err)
+		}
+		r.stopLocal(name)
+	}
+	err = r.updateRecord(name, func(rec *store.Record) {
+		rec.Expired = true
+	})
+	if err != nil {
+		log.Println("Expire "+name, err)
+	}
+	r.publish(store.EventExpire, name)
+	log.Println("Expire User" + name)
+}
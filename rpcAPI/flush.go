@@ -0,0 +1,220 @@
+package rpcapi
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BishiNET/ss-server/server"
+	"github.com/BishiNET/ss-server/store"
+)
+
+// counters is a snapshot of a user's counters.
+type counters struct {
+	traffic uint64
+	time    int64
+	detail  server.TrafficDetail
+}
+
+// flusher remembers what has been persisted, so that only the deltas are
+// added to the store and nodes sharing the Redis don't clobber each other.
+type flusher struct {
+	lock    sync.Mutex
+	flushed map[string]counters
+
+	tickLock sync.Mutex
+	ticker   *time.Ticker
+	stop     chan struct{}
+}
+
+func newFlusher() *flusher {
+	return &flusher{
+		flushed: make(map[string]counters),
+	}
+}
+
+func (r *UserRpc) snapshot(name string) counters {
+	traffic, time := r.Users.GetUser(name)
+	return counters{
+		traffic: traffic,
+		time:    time,
+		detail:  r.Users.GetUserDetail(name),
+	}
+}
+
+// markFlushed records the counters just restored from Redis as persisted.
+func (r *UserRpc) markFlushed(name string) {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	r.flusher.flushed[name] = r.snapshot(name)
+}
+
+func (r *UserRpc) forgetFlushed(name string) {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	delete(r.flusher.flushed, name)
+}
+
+// flush adds the deltas since the last flush of the users to the store.
+// It is called with flusher.lock held, taken before the snapshot of the
+// users so that a reset in between isn't written back.
+func (r *UserRpc) flush(users map[string]counters) error {
+	deltas := make(map[string]store.Counters, len(users))
+	for name, now := range users {
+		last := r.flusher.flushed[name]
+		if now.traffic < last.traffic || now.time < last.time {
+			// reset without going through resetFlushed, nothing sane to add
+			r.flusher.flushed[name] = now
+			delete(users, name)
+			continue
+		}
+		deltas[name] = store.Counters{
+			Traffic: now.traffic - last.traffic,
+			Time:    now.time - last.time,
+			TCPUp:   now.detail.TCPUpload - last.detail.TCPUpload,
+			TCPDown: now.detail.TCPDownload - last.detail.TCPDownload,
+			UDPUp:   now.detail.UDPUpload - last.detail.UDPUpload,
+			UDPDown: now.detail.UDPDownload - last.detail.UDPDownload,
+		}
+	}
+	if err := r.st.AddCounters(deltas); err != nil {
+		return err
+	}
+	if err := r.st.AddUsage(deltas, time.Now()); err != nil {
+		log.Println("Usage", err)
+	}
+	for name, now := range users {
+		r.flusher.flushed[name] = now
+	}
+	return nil
+}
+
+// flushUser persists the deltas of a running user.
+func (r *UserRpc) flushUser(name string) error {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	return r.flush(map[string]counters{name: r.snapshot(name)})
+}
+
+// resetFlushed resets a running user and its persisted counters.
+func (r *UserRpc) resetFlushed(name string) error {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	r.Users.ResetUser(name)
+	r.flusher.flushed[name] = counters{}
+	return r.st.ResetCounters(name)
+}
+
+// zeroFlushed resets a running user whose persisted counters have been
+// reset by another node.
+func (r *UserRpc) zeroFlushed(name string) {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	r.Users.ResetUser(name)
+	r.flusher.flushed[name] = counters{}
+}
+
+// resetRunning resets the running users, and their persisted counters
+// unless another node has reset them. The first failure is returned.
+func (r *UserRpc) resetRunning(persisted bool) error {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	r.Users.ResetAll()
+	var err error
+	for name := range r.runningUsers() {
+		r.flusher.flushed[name] = counters{}
+		if !persisted {
+			continue
+		}
+		if e := r.st.ResetCounters(name); e != nil {
+			log.Println("Reset "+name, e)
+			if err == nil {
+				err = storeErr(e)
+			}
+		}
+	}
+	return err
+}
+
+func storeCounters(c counters) store.Counters {
+	return store.Counters{
+		Traffic: c.traffic,
+		Time:    c.time,
+		TCPUp:   c.detail.TCPUpload,
+		TCPDown: c.detail.TCPDownload,
+		UDPUp:   c.detail.UDPUpload,
+		UDPDown: c.detail.UDPDownload,
+	}
+}
+
+func fromStore(c store.Counters) counters {
+	return counters{
+		traffic: c.Traffic,
+		time:    c.Time,
+		detail: server.TrafficDetail{
+			TCPUpload:   c.TCPUp,
+			TCPDownload: c.TCPDown,
+			UDPUpload:   c.UDPUp,
+			UDPDownload: c.UDPDown,
+		},
+	}
+}
+
+// Flush persists the deltas of all running users, and pulls the traffic
+// of the other nodes for their quotas.
+func (r *UserRpc) Flush() {
+	r.flushAll()
+	for name := range r.runningUsers() {
+		r.pullRemote(name)
+	}
+}
+
+func (r *UserRpc) flushAll() {
+	r.flusher.lock.Lock()
+	defer r.flusher.lock.Unlock()
+	users := map[string]counters{}
+	executor := func(name string, traffic uint64, usedtime int64, detail server.TrafficDetail) {
+		users[name] = counters{
+			traffic: traffic,
+			time:    usedtime,
+			detail:  detail,
+		}
+	}
+	r.Users.GetAll(executor)
+	if err := r.flush(users); err != nil {
+		log.Println("Flush", err)
+	}
+}
+
+// StartFlusher persists the counters every interval in background.
+// Calling it again changes the interval, 0 stops it.
+func (r *UserRpc) StartFlusher(interval time.Duration) {
+	f := r.flusher
+	f.tickLock.Lock()
+	defer f.tickLock.Unlock()
+	if f.ticker != nil {
+		if interval > 0 {
+			f.ticker.Reset(interval)
+			return
+		}
+		f.ticker.Stop()
+		close(f.stop)
+		f.ticker, f.stop = nil, nil
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+	ticker, stop := time.NewTicker(interval), make(chan struct{})
+	f.ticker, f.stop = ticker, stop
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.Flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,263 @@
+package rpcapi
+
+import (
+	"context"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/rpcinterface/pb"
+	"github.com/BishiNET/ss-server/server"
+	reuse "github.com/libp2p/go-reuseport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultWatchInterval = time.Second
+
+// grpcServer serves pb.UserRpc by calling the net/rpc methods of UserRpc.
+type grpcServer struct {
+	pb.UnimplementedUserRpcServer
+	r *UserRpc
+}
+
+func fromAuth(a *pb.Auth) R.Auth {
+	if a == nil {
+		return R.Auth{}
+	}
+	return R.Auth{
+		AccessID:    a.AccessId,
+		AccessToken: a.AccessToken,
+		Timestamp:   a.Timestamp,
+		Sign:        a.Sign,
+	}
+}
+
+// grpcError maps an error of UserRpc to a gRPC status.
+func grpcError(err error) error {
+	switch errCode(err) {
+	case USER_EXISTS:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case USER_NON_EXISTS:
+		return status.Error(codes.NotFound, err.Error())
+	case AUTH_ERROR:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case REDIS_ERROR, FILTER_ERROR:
+		return status.Error(codes.Unavailable, err.Error())
+	case PORT_IN_USE, USER_EXPIRED:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case LISTEN_ERROR:
+		return status.Error(codes.Internal, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func callReplyPB(err error) (*pb.CallReply, error) {
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.CallReply{ErrCode: NO_ERROR}, nil
+}
+
+func commonArgs(in *pb.CommonArgs) *R.CommonArgs {
+	return &R.CommonArgs{
+		Auth:     fromAuth(in.Auth),
+		Name:     in.Name,
+		Password: in.Password,
+		Cipher:   in.Cipher,
+		ExpireAt: in.ExpireAt,
+	}
+}
+
+func trafficPB(t R.SingleTrafficReply) *pb.Traffic {
+	return &pb.Traffic{
+		Traffic:     t.Traffic,
+		UsedTime:    t.UsedTime,
+		Upload:      t.Upload,
+		Download:    t.Download,
+		TcpUpload:   t.TCPUpload,
+		TcpDownload: t.TCPDownload,
+		UdpUpload:   t.UDPUpload,
+		UdpDownload: t.UDPDownload,
+	}
+}
+
+func (s *grpcServer) AddUser(_ context.Context, in *pb.NewUserArgs) (*pb.CallReply, error) {
+	args := &R.NewUserArgs{
+		Auth:      fromAuth(in.Auth),
+		Name:      in.Name,
+		Cipher:    in.Cipher,
+		Password:  in.Password,
+		Port:      in.Port,
+		Quota:     in.Quota,
+		UpLimit:   in.UpLimit,
+		DownLimit: in.DownLimit,
+		ExpireAt:  in.ExpireAt,
+	}
+	return callReplyPB(s.r.addUser(args))
+}
+
+func (s *grpcServer) StartUser(_ context.Context, in *pb.CommonArgs) (*pb.CallReply, error) {
+	return callReplyPB(s.r.startUserCall(commonArgs(in)))
+}
+
+func (s *grpcServer) StopUser(_ context.Context, in *pb.CommonArgs) (*pb.CallReply, error) {
+	return callReplyPB(s.r.stopUser(commonArgs(in)))
+}
+
+func (s *grpcServer) DeleteUser(_ context.Context, in *pb.CommonArgs) (*pb.CallReply, error) {
+	return callReplyPB(s.r.deleteUser(commonArgs(in)))
+}
+
+func (s *grpcServer) Modify(_ context.Context, in *pb.CommonArgs) (*pb.CallReply, error) {
+	return callReplyPB(s.r.modify(commonArgs(in)))
+}
+
+func (s *grpcServer) GetUser(_ context.Context, in *pb.CommonArgs) (*pb.TrafficReply, error) {
+	reply, err := s.r.getUser(commonArgs(in))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	users := make(map[string]*pb.Traffic, len(reply))
+	for name, t := range reply {
+		users[name] = trafficPB(t)
+	}
+	return &pb.TrafficReply{Users: users}, nil
+}
+
+func (s *grpcServer) ResetAll(_ context.Context, in *pb.NoArgs) (*pb.CallReply, error) {
+	args := &R.NoArgs{Auth: fromAuth(in.Auth)}
+	return callReplyPB(s.r.resetAll(args))
+}
+
+func (s *grpcServer) UpgradeFilter(_ context.Context, in *pb.NoArgs) (*pb.CallReply, error) {
+	args := &R.NoArgs{Auth: fromAuth(in.Auth)}
+	return callReplyPB(s.r.upgradeFilter(args))
+}
+
+func (s *grpcServer) AddFilter(_ context.Context, in *pb.Filters) (*pb.FilterReply, error) {
+	args := &R.Filters{Auth: fromAuth(in.Auth), URL: in.Url}
+	sources, err := s.r.addFilter(args)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.FilterReply{ErrCode: NO_ERROR, Sources: filterSourcesPB(sources)}, nil
+}
+
+func filterSourcesPB(sources []R.FilterSource) []*pb.FilterSource {
+	var out []*pb.FilterSource
+	for _, v := range sources {
+		out = append(out, &pb.FilterSource{
+			Url:      v.URL,
+			Format:   v.Format,
+			Accepted: int64(v.Accepted),
+			Rejected: int64(v.Rejected),
+			Error:    v.Error,
+			Cached:   v.Cached,
+			Checked:  v.Checked,
+			Updated:  v.Updated,
+		})
+	}
+	return out
+}
+
+func (s *grpcServer) FilterStatus(_ context.Context, in *pb.NoArgs) (*pb.FilterStatusReply, error) {
+	st, err := s.r.filterStatus(&R.NoArgs{Auth: fromAuth(in.Auth)})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.FilterStatusReply{
+		ErrCode:         NO_ERROR,
+		Backend:         st.Backend,
+		Domains:         int64(st.Domains),
+		Memory:          st.Memory,
+		Built:           st.Built,
+		Checked:         st.Checked,
+		Error:           st.Error,
+		RefreshInterval: st.RefreshInterval,
+		Sources:         filterSourcesPB(st.Sources),
+	}, nil
+}
+
+// delta returns how much now grew since last, or now if the counters
+// have been reset in between.
+func delta(now, last R.SingleTrafficReply) R.SingleTrafficReply {
+	if now.Traffic < last.Traffic || now.UsedTime < last.UsedTime {
+		return now
+	}
+	return R.SingleTrafficReply{
+		Traffic:     now.Traffic - last.Traffic,
+		UsedTime:    now.UsedTime - last.UsedTime,
+		Upload:      now.Upload - last.Upload,
+		Download:    now.Download - last.Download,
+		TCPUpload:   now.TCPUpload - last.TCPUpload,
+		TCPDownload: now.TCPDownload - last.TCPDownload,
+		UDPUpload:   now.UDPUpload - last.UDPUpload,
+		UDPDownload: now.UDPDownload - last.UDPDownload,
+	}
+}
+
+// watchSnapshot returns the counters of name, or of all users if name is empty.
+func (r *UserRpc) watchSnapshot(name string) R.TrafficReply {
+	users := R.TrafficReply{}
+	r.Users.GetAll(func(n string, traffic uint64, usedtime int64, detail server.TrafficDetail) {
+		if name == "" || n == name {
+			users[n] = trafficReply(traffic, usedtime, detail)
+		}
+	})
+	return users
+}
+
+func (s *grpcServer) WatchTraffic(in *pb.WatchArgs, stream pb.UserRpc_WatchTrafficServer) error {
+	args := &R.WatchArgs{
+		Auth:       fromAuth(in.Auth),
+		Name:       in.Name,
+		IntervalMs: in.IntervalMs,
+	}
+	if err := s.r.authorize("UserRpc.WatchTraffic", args); err != nil {
+		return grpcError(err)
+	}
+	interval := time.Duration(in.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := s.r.watchSnapshot(in.Name)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+		now := s.r.watchSnapshot(in.Name)
+		for name, t := range now {
+			d := delta(t, last[name])
+			if d == (R.SingleTrafficReply{}) {
+				continue
+			}
+			if err := stream.Send(&pb.TrafficDelta{
+				Name:  name,
+				Delta: trafficPB(d),
+				Total: trafficPB(t),
+			}); err != nil {
+				return err
+			}
+		}
+		last = now
+	}
+}
+
+// ServeGRPC serves the gRPC API on addr until Close.
+func (r *UserRpc) ServeGRPC(addr string) error {
+	l, err := reuse.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	pb.RegisterUserRpcServer(s, &grpcServer{r: r})
+	r.grpcS = s
+	go s.Serve(l)
+	return nil
+}
@@ -0,0 +1,150 @@
+package rpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BishiNET/ss-server/rpcinterface/pb"
+	"github.com/BishiNET/ss-server/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testGRPC serves r over an in-memory gRPC connection.
+func testGRPC(t *testing.T, r *UserRpc) (pb.UserRpcClient, *grpc.Server) {
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterUserRpcServer(s, &grpcServer{r: r})
+	go s.Serve(l)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return pb.NewUserRpcClient(conn), s
+}
+
+func expectStatus(t *testing.T, what string, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Errorf("%s: %v, want %s", what, err, code)
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{errUserExists, codes.AlreadyExists},
+		{errUserNonExists, codes.NotFound},
+		{errUnknownID, codes.Unauthenticated},
+		{&storeError{errors.New("down")}, codes.Unavailable},
+		{&filterError{errors.New("status 500")}, codes.Unavailable},
+		{server.ErrDuplicateKey, codes.FailedPrecondition},
+		{errUserExpired, codes.FailedPrecondition},
+		{&net.OpError{Op: "listen", Err: errors.New("denied")}, codes.Internal},
+		{server.ErrCipherNotSupported, codes.InvalidArgument},
+		{server.KeySizeError(16), codes.InvalidArgument},
+		{errBadExpiry, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		expectStatus(t, tt.err.Error(), grpcError(tt.err), tt.code)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	c, _ := testGRPC(t, r)
+	ctx := context.Background()
+
+	args := &pb.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	reply, err := c.AddUser(ctx, args)
+	if err != nil || reply.ErrCode != NO_ERROR {
+		t.Fatalf("add: %v %v", reply, err)
+	}
+	_, err = c.AddUser(ctx, args)
+	expectStatus(t, "add again", err, codes.AlreadyExists)
+	_, err = c.StopUser(ctx, &pb.CommonArgs{Name: "x"})
+	expectStatus(t, "stop unknown", err, codes.NotFound)
+	_, err = c.Modify(ctx, &pb.CommonArgs{Name: "a", Cipher: "bogus"})
+	expectStatus(t, "bad cipher", err, codes.InvalidArgument)
+
+	traffic, err := c.GetUser(ctx, &pb.CommonArgs{Name: "a"})
+	if err != nil || traffic.Users["a"] == nil {
+		t.Fatalf("get: %v %v", traffic, err)
+	}
+
+	r.SetAuthKeys(map[string]string{"id": "secret"}, time.Minute)
+	_, err = c.GetUser(ctx, &pb.CommonArgs{Name: "a"})
+	expectStatus(t, "unsigned", err, codes.Unauthenticated)
+	stream, err := c.WatchTraffic(ctx, &pb.WatchArgs{Name: "a"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectStatus(t, "unsigned watch", err, codes.Unauthenticated)
+}
+
+func TestWatchTraffic(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	c, s := testGRPC(t, r)
+	for _, name := range []string{"a", "b"} {
+		args := &pb.NewUserArgs{Name: name, Cipher: "AES-256-GCM", Password: name, Port: freePort(t)}
+		if _, err := c.AddUser(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.WatchTraffic(ctx, &pb.WatchArgs{Name: "a", IntervalMs: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream is set up once the first tick passed
+	time.Sleep(100 * time.Millisecond)
+	r.Users.SetUser("b", 50, 1)
+	r.Users.SetUser("a", 100, 1)
+	d, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "a" || d.Delta.Traffic != 100 || d.Total.Traffic != 100 {
+		t.Fatalf("delta %v", d)
+	}
+	r.Users.SetUser("a", 150, 2)
+	if d, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Delta.Traffic != 50 || d.Total.Traffic != 150 {
+		t.Fatalf("delta %v", d)
+	}
+
+	// the server ends the stream once the client cancels
+	cancel()
+	_, err = stream.Recv()
+	expectStatus(t, "canceled", err, codes.Canceled)
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still served after the cancel")
+	}
+}
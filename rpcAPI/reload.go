@@ -0,0 +1,98 @@
+package rpcapi
+
+import (
+	"log"
+	"sync"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/server"
+)
+
+// Reloader re-reads the config and applies it, returning the settings
+// applied live and the ones requiring a restart.
+type Reloader func() (live, restart []string, err error)
+
+type reloadState struct {
+	lock      sync.RWMutex
+	reloader  Reloader
+	upLimit   int64
+	downLimit int64
+}
+
+func (r *UserRpc) SetReloader(f Reloader) {
+	r.reload.lock.Lock()
+	defer r.reload.lock.Unlock()
+	r.reload.reloader = f
+}
+
+// rateLimit fills the limits the user hasn't set with the defaults.
+func (r *UserRpc) rateLimit(up, down int64) (int64, int64) {
+	r.reload.lock.RLock()
+	defer r.reload.lock.RUnlock()
+	if up == 0 {
+		up = r.reload.upLimit
+	}
+	if down == 0 {
+		down = r.reload.downLimit
+	}
+	return up, down
+}
+
+// userRateLimit returns the effective limits of a user as stored.
+func (r *UserRpc) userRateLimit(name string) (int64, int64) {
+	var up, down int64
+	if su, err := r.st.Get(name); err == nil {
+		up, down = su.UpLimit, su.DownLimit
+	}
+	return r.rateLimit(up, down)
+}
+
+// SetDefaultRateLimit sets the limits of the users without their own,
+// the running users included.
+func (r *UserRpc) SetDefaultRateLimit(up, down int64) {
+	r.reload.lock.Lock()
+	r.reload.upLimit, r.reload.downLimit = up, down
+	r.reload.lock.Unlock()
+
+	users := map[string]struct{}{}
+	r.Users.GetAll(func(name string, _ uint64, _ int64, _ server.TrafficDetail) {
+		users[name] = struct{}{}
+	})
+	for name := range users {
+		up, down := r.userRateLimit(name)
+		if r.Users.Exists(name) {
+			r.Users.SetUserRateLimit(name, up, down)
+		}
+	}
+}
+
+func (r *UserRpc) Reload(args *R.NoArgs, reply *R.ReloadReply) error {
+	live, restart, err := r.reloadConfig(args)
+	*reply = R.ReloadReply{
+		ErrCode:         errCode(err),
+		Applied:         live,
+		RestartRequired: restart,
+	}
+	if err != nil {
+		reply.ErrReason = err.Error()
+	}
+	return nil
+}
+
+func (r *UserRpc) reloadConfig(args *R.NoArgs) ([]string, []string, error) {
+	if err := r.authorize("UserRpc.Reload", args); err != nil {
+		return nil, nil, err
+	}
+	r.reload.lock.RLock()
+	f := r.reload.reloader
+	r.reload.lock.RUnlock()
+	if f == nil {
+		return nil, nil, errReloadNotSupported
+	}
+	live, restart, err := f()
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Println("Reload Config")
+	return live, restart, nil
+}
@@ -0,0 +1,176 @@
+package rpcapi
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/store"
+)
+
+var errBadSchedule = errors.New("invalid reset schedule")
+
+// monthDay is the day of the month at midnight, the last day of the month
+// if it is shorter.
+func monthDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
+
+// nextReset is the first reset of the schedule after t, 0 without one.
+func nextReset(rec store.Record, t time.Time) int64 {
+	switch {
+	case rec.ResetDay > 0:
+		year, month, _ := t.Date()
+		next := monthDay(year, month, rec.ResetDay, t.Location())
+		if !next.After(t) {
+			next = monthDay(year, month+1, rec.ResetDay, t.Location())
+		}
+		return next.Unix()
+	case rec.ResetDays > 0:
+		period := int64(rec.ResetDays) * int64(24*time.Hour/time.Second)
+		n := (t.Unix()-rec.ResetAnchor)/period + 1
+		if n < 1 {
+			n = 1
+		}
+		return rec.ResetAnchor + n*period
+	}
+	return 0
+}
+
+// scheduleReset resets the counters of the user at at, 0 cancels it.
+func (r *UserRpc) scheduleReset(name string, at int64) {
+	r.resets.schedule(name, at, r.resetScheduled)
+}
+
+func (r *UserRpc) resetScheduled(name string) {
+	defer r.locks.hold(name)()
+	su, err := r.st.Get(name)
+	if err != nil {
+		log.Println("Reset "+name, err)
+		return
+	}
+	if err := r.resetDue(name, su, time.Now()); err != nil {
+		log.Println("Reset "+name, err)
+	}
+	if r.Users.Exists(name) {
+		r.scheduleReset(name, su.NextReset)
+	}
+}
+
+// resetDue resets the counters of a user if its reset is due, saving them
+// into the history first. su is updated. It is called with the lock of
+// the user held, so that it stays running or stopped meanwhile.
+func (r *UserRpc) resetDue(name string, su *store.User, now time.Time) error {
+	if su.NextReset == 0 || su.NextReset > now.Unix() {
+		return nil
+	}
+	at, next := su.NextReset, nextReset(su.Record, now)
+	// claim the reset first, so that the other nodes skip it
+	claimed, err := r.st.ClaimReset(name, at, next)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		// reset by another node, which publishes it
+		if cur, err := r.st.Get(name); err == nil {
+			*su = *cur
+		}
+		return nil
+	}
+	su.NextReset = next
+	running := r.Users.Exists(name)
+	c := fromStore(su.Counters)
+	if running {
+		// no flush may come between the snapshot and the reset
+		r.flusher.lock.Lock()
+		defer r.flusher.lock.Unlock()
+		c = r.snapshot(name)
+		if err := r.flush(map[string]counters{name: c}); err != nil {
+			log.Println("Flush "+name, err)
+		}
+	}
+	c = r.totalCounters(name, c)
+	if err := r.st.AddHistory(name, store.History{At: at, Counters: storeCounters(c)}); err != nil {
+		return err
+	}
+	if running {
+		r.Users.ResetUser(name)
+		r.flusher.flushed[name] = counters{}
+	}
+	err = r.st.ResetCounters(name)
+	su.Counters = store.Counters{}
+	r.publish(store.EventReset, name)
+	log.Println("Reset User" + name)
+	return err
+}
+
+func (r *UserRpc) SetResetSchedule(args *R.ResetScheduleArgs, reply *R.CallReply) error {
+	return callResult(reply, r.setResetSchedule(args))
+}
+
+func (r *UserRpc) setResetSchedule(args *R.ResetScheduleArgs) error {
+	if err := r.authorize("UserRpc.SetResetSchedule", args); err != nil {
+		return err
+	}
+	if args.Day < 0 || args.Day > 31 || args.Days < 0 || (args.Day > 0 && args.Days > 0) {
+		return errBadSchedule
+	}
+	now := time.Now()
+	var next int64
+	err := r.updateRecord(args.Name, func(rec *store.Record) {
+		// the same schedule sent again keeps its resets
+		if rec.ResetDay == args.Day && rec.ResetDays == args.Days && rec.NextReset > 0 {
+			next = rec.NextReset
+			return
+		}
+		rec.ResetDay, rec.ResetDays, rec.ResetAnchor = args.Day, args.Days, 0
+		if args.Days > 0 {
+			rec.ResetAnchor = now.Unix()
+		}
+		rec.NextReset = nextReset(*rec, now)
+		next = rec.NextReset
+	})
+	if err != nil {
+		return err
+	}
+	if r.Users.Exists(args.Name) {
+		r.scheduleReset(args.Name, next)
+	}
+	r.publish(store.EventUpdate, args.Name)
+	log.Println("Set Reset Schedule" + args.Name)
+	return nil
+}
+
+// GetHistory has no error code in its reply, failures are returned as errors.
+func (r *UserRpc) GetHistory(args *R.CommonArgs, reply *R.HistoryReply) error {
+	h, err := r.getHistory(args)
+	if err != nil {
+		return err
+	}
+	*reply = h
+	return nil
+}
+
+func (r *UserRpc) getHistory(args *R.CommonArgs) (R.HistoryReply, error) {
+	if err := r.authorize("UserRpc.GetHistory", args); err != nil {
+		return nil, err
+	}
+	hs, err := r.st.History(args.Name)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	reply := make(R.HistoryReply, len(hs))
+	for i, h := range hs {
+		c := fromStore(h.Counters)
+		reply[i] = R.HistoryEntry{
+			At:                 h.At,
+			SingleTrafficReply: trafficReply(c.traffic, c.time, c.detail),
+		}
+	}
+	return reply, nil
+}
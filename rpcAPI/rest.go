@@ -0,0 +1,508 @@
+package rpcapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	reuse "github.com/libp2p/go-reuseport"
+)
+
+// The signature of a REST call is passed in these headers, computed by
+// rpcinterface.Sign over the RPC method of the route and the JSON args.
+const (
+	headerAccessID    = "X-Access-Id"
+	headerAccessToken = "X-Access-Token"
+	headerTimestamp   = "X-Timestamp"
+	headerSign        = "X-Sign"
+)
+
+// route maps a REST endpoint to a UserRpc method.
+type route struct {
+	method string
+	// path segments, "{name}" is the user name
+	path    string
+	rpc     string
+	summary string
+	// body tells whether the args are read from the JSON body
+	body   bool
+	status int
+	args   func() R.Signed
+	// query names the args fields read from the query string, in lower case
+	query []string
+	reply interface{}
+	call  func(r *UserRpc, args R.Signed) (interface{}, error)
+}
+
+func callReply(err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	return R.CallReply{ErrCode: NO_ERROR}, nil
+}
+
+var routes = []route{
+	{
+		method: "GET", path: "/users", rpc: "UserRpc.GetUser",
+		summary: "List the traffic of all running users",
+		status:  http.StatusOK, reply: R.TrafficReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return r.getUser(args.(*R.CommonArgs))
+		},
+	},
+	{
+		method: "POST", path: "/users", rpc: "UserRpc.AddUser",
+		summary: "Add and start a user",
+		body:    true, status: http.StatusCreated, reply: R.CallReply{},
+		args: func() R.Signed { return &R.NewUserArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.addUser(args.(*R.NewUserArgs)))
+		},
+	},
+	{
+		method: "GET", path: "/users/{name}", rpc: "UserRpc.GetUser",
+		summary: "Get the traffic of a running user",
+		status:  http.StatusOK, reply: R.TrafficReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return r.getUser(args.(*R.CommonArgs))
+		},
+	},
+	{
+		method: "PATCH", path: "/users/{name}", rpc: "UserRpc.Modify",
+		summary: "Change the password or the cipher of a user",
+		body:    true, status: http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.modify(args.(*R.CommonArgs)))
+		},
+	},
+	{
+		method: "DELETE", path: "/users/{name}", rpc: "UserRpc.DeleteUser",
+		summary: "Stop a user and delete it with its traffic",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.deleteUser(args.(*R.CommonArgs)))
+		},
+	},
+	{
+		method: "POST", path: "/users/{name}/start", rpc: "UserRpc.StartUser",
+		summary: "Start a stopped user",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.startUserCall(args.(*R.CommonArgs)))
+		},
+	},
+	{
+		method: "POST", path: "/users/{name}/stop", rpc: "UserRpc.StopUser",
+		summary: "Stop a user, keeping its traffic",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.stopUser(args.(*R.CommonArgs)))
+		},
+	},
+	{
+		method: "PUT", path: "/users/{name}/quota", rpc: "UserRpc.SetQuota",
+		summary: "Set the traffic quota of a user",
+		body:    true, status: http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.QuotaArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.setQuota(args.(*R.QuotaArgs)))
+		},
+	},
+	{
+		method: "PUT", path: "/users/{name}/ratelimit", rpc: "UserRpc.SetRateLimit",
+		summary: "Set the bandwidth limits of a user",
+		body:    true, status: http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.RateLimitArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.setRateLimit(args.(*R.RateLimitArgs)))
+		},
+	},
+	{
+		method: "PUT", path: "/users/{name}/reset-schedule", rpc: "UserRpc.SetResetSchedule",
+		summary: "Set when the traffic of a user is reset",
+		body:    true, status: http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.ResetScheduleArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.setResetSchedule(args.(*R.ResetScheduleArgs)))
+		},
+	},
+	{
+		method: "GET", path: "/users/{name}/history", rpc: "UserRpc.GetHistory",
+		summary: "List the traffic of a user at its past resets",
+		status:  http.StatusOK, reply: R.HistoryReply{},
+		args: func() R.Signed { return &R.CommonArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return r.getHistory(args.(*R.CommonArgs))
+		},
+	},
+	{
+		method: "GET", path: "/users/{name}/usage", rpc: "UserRpc.GetUsage",
+		summary: "List the hourly or daily traffic of a user",
+		status:  http.StatusOK, reply: R.UsageReply{},
+		args:  func() R.Signed { return &R.UsageArgs{} },
+		query: []string{"Period", "From", "To"},
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return r.getUsage(args.(*R.UsageArgs))
+		},
+	},
+	{
+		method: "POST", path: "/reset", rpc: "UserRpc.ResetAll",
+		summary: "Reset the traffic of all users",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.NoArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.resetAll(args.(*R.NoArgs)))
+		},
+	},
+	{
+		method: "POST", path: "/restore", rpc: "UserRpc.Restore",
+		summary: "Start all stored users",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.NoArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.restore(args.(*R.NoArgs)))
+		},
+	},
+	{
+		method: "POST", path: "/filters", rpc: "UserRpc.AddFilter",
+		summary: "Add blocklist URLs to the domain filter",
+		body:    true, status: http.StatusOK, reply: R.FilterReply{},
+		args: func() R.Signed { return &R.Filters{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			sources, err := r.addFilter(args.(*R.Filters))
+			if err != nil {
+				return nil, err
+			}
+			return R.FilterReply{Sources: sources}, nil
+		},
+	},
+	{
+		method: "GET", path: "/filters", rpc: "UserRpc.FilterStatus",
+		summary: "Get the domain filter and the state of the blocklists",
+		status:  http.StatusOK, reply: R.FilterStatusReply{},
+		args: func() R.Signed { return &R.NoArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			st, err := r.filterStatus(args.(*R.NoArgs))
+			if err != nil {
+				return nil, err
+			}
+			return st, nil
+		},
+	},
+	{
+		method: "POST", path: "/filters/upgrade", rpc: "UserRpc.UpgradeFilter",
+		summary: "Download the blocklists again",
+		status:  http.StatusOK, reply: R.CallReply{},
+		args: func() R.Signed { return &R.NoArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			return callReply(r.upgradeFilter(args.(*R.NoArgs)))
+		},
+	},
+	{
+		method: "POST", path: "/reload", rpc: "UserRpc.Reload",
+		summary: "Reload the config",
+		status:  http.StatusOK, reply: R.ReloadReply{},
+		args: func() R.Signed { return &R.NoArgs{} },
+		call: func(r *UserRpc, args R.Signed) (interface{}, error) {
+			live, restart, err := r.reloadConfig(args.(*R.NoArgs))
+			if err != nil {
+				return nil, err
+			}
+			return R.ReloadReply{Applied: live, RestartRequired: restart}, nil
+		},
+	},
+}
+
+// match tells whether path matches the route and returns the user name in it.
+func (rt *route) match(path string) (string, bool) {
+	want := strings.Split(strings.Trim(rt.path, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return "", false
+	}
+	var name string
+	for i := range want {
+		switch {
+		case want[i] == "{name}" && got[i] != "":
+			name = got[i]
+		case want[i] != got[i]:
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// errStatus maps an error of UserRpc to the HTTP status and the error code.
+func errStatus(err error) (int, int) {
+	code := errCode(err)
+	switch code {
+	case USER_EXISTS, PORT_IN_USE:
+		return http.StatusConflict, code
+	case USER_NON_EXISTS:
+		return http.StatusNotFound, code
+	case USER_EXPIRED:
+		return http.StatusGone, code
+	case AUTH_ERROR:
+		return http.StatusUnauthorized, code
+	case REDIS_ERROR:
+		return http.StatusServiceUnavailable, code
+	case LISTEN_ERROR:
+		return http.StatusInternalServerError, code
+	case FILTER_ERROR:
+		return http.StatusBadGateway, code
+	}
+	return http.StatusBadRequest, code
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("REST write", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status, code int, reason string) {
+	writeJSON(w, status, R.CallReply{
+		ErrCode:   code,
+		ErrReason: reason,
+	})
+}
+
+// setName sets the Name field of args, if any, to the name in the path.
+func setName(args R.Signed, name string) {
+	if f := reflect.ValueOf(args).Elem().FieldByName("Name"); f.IsValid() && f.Kind() == reflect.String {
+		f.SetString(name)
+	}
+}
+
+// setQuery sets the fields of args named by the route from the query.
+func setQuery(args R.Signed, rt *route, q url.Values) error {
+	v := reflect.ValueOf(args).Elem()
+	for _, name := range rt.query {
+		s := q.Get(strings.ToLower(name))
+		if s == "" {
+			continue
+		}
+		f := v.FieldByName(name)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(s)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", strings.ToLower(name), s)
+			}
+			f.SetInt(n)
+		}
+	}
+	return nil
+}
+
+func (r *UserRpc) serveRoute(w http.ResponseWriter, req *http.Request, rt *route, name string) {
+	args := rt.args()
+	if rt.body {
+		b, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, PARAMS_ERROR, err.Error())
+			return
+		}
+		if err := json.Unmarshal(b, args); err != nil {
+			writeError(w, http.StatusBadRequest, PARAMS_ERROR, err.Error())
+			return
+		}
+	}
+	if err := setQuery(args, rt, req.URL.Query()); err != nil {
+		writeError(w, http.StatusBadRequest, PARAMS_ERROR, err.Error())
+		return
+	}
+	if strings.Contains(rt.path, "{name}") {
+		setName(args, name)
+	}
+	a := args.Credentials()
+	a.AccessID = req.Header.Get(headerAccessID)
+	a.AccessToken = req.Header.Get(headerAccessToken)
+	a.Timestamp, _ = strconv.ParseInt(req.Header.Get(headerTimestamp), 10, 64)
+	a.Sign = req.Header.Get(headerSign)
+
+	reply, err := rt.call(r, args)
+	if err != nil {
+		status, code := errStatus(err)
+		writeError(w, status, code, err.Error())
+		return
+	}
+	writeJSON(w, rt.status, reply)
+}
+
+// ServeHTTP serves the REST API under /api.
+func (r *UserRpc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/api")
+	if path == "/openapi.json" && req.Method == "GET" {
+		writeJSON(w, http.StatusOK, openAPI())
+		return
+	}
+	found := false
+	for i := range routes {
+		rt := &routes[i]
+		name, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		found = true
+		if rt.method == req.Method {
+			r.serveRoute(w, req, rt, name)
+			return
+		}
+	}
+	if found {
+		writeError(w, http.StatusMethodNotAllowed, PARAMS_ERROR, "method not allowed")
+		return
+	}
+	writeError(w, http.StatusNotFound, PARAMS_ERROR, "not found")
+}
+
+// ServeREST serves the REST API on addr until Close.
+func (r *UserRpc) ServeREST(addr string) error {
+	l, err := reuse.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	r.restL = l
+	go http.Serve(l, r)
+	return nil
+}
+
+// openAPI describes the routes in OpenAPI 3.
+func openAPI() map[string]interface{} {
+	paths := map[string]interface{}{}
+	errResponse := map[string]interface{}{
+		"description": "error, ErrCode tells the reason",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(R.CallReply{}))},
+		},
+	}
+	for _, rt := range routes {
+		params := []interface{}{}
+		if strings.Contains(rt.path, "{name}") {
+			params = append(params, map[string]interface{}{
+				"name": "name", "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range rt.query {
+			f, _ := reflect.TypeOf(rt.args()).Elem().FieldByName(name)
+			params = append(params, map[string]interface{}{
+				"name": strings.ToLower(name), "in": "query",
+				"schema": schemaOf(f.Type),
+			})
+		}
+		for _, h := range []string{headerAccessID, headerAccessToken, headerTimestamp, headerSign} {
+			params = append(params, map[string]interface{}{
+				"name": h, "in": "header",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		op := map[string]interface{}{
+			"operationId": strings.TrimPrefix(rt.rpc, "UserRpc.") + operationSuffix(rt),
+			"summary":     rt.summary,
+			"description": "Signed as " + rt.rpc + ".",
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				strconv.Itoa(rt.status): map[string]interface{}{
+					"description": "success",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.reply))},
+					},
+				},
+				"default": errResponse,
+			},
+		}
+		if rt.body {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.args()).Elem())},
+				},
+			}
+		}
+		p := "/api" + rt.path
+		item, ok := paths[p].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[p] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "ss-server management API",
+			"version": "1",
+		},
+		"paths": paths,
+	}
+}
+
+// operationSuffix tells apart the routes sharing an RPC method.
+func operationSuffix(rt route) string {
+	if rt.rpc == "UserRpc.GetUser" && !strings.Contains(rt.path, "{name}") {
+		return "s"
+	}
+	return ""
+}
+
+var authType = reflect.TypeOf(R.Auth{})
+
+// schemaOf describes t in JSON schema, leaving out the signature passed
+// in the headers.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Type == authType || !f.IsExported() {
+				continue
+			}
+			// embedded fields are flattened by encoding/json
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				for k, v := range schemaOf(f.Type)["properties"].(map[string]interface{}) {
+					props[k] = v
+				}
+				continue
+			}
+			props[f.Name] = schemaOf(f.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	}
+	return map[string]interface{}{}
+}
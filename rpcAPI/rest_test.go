@@ -0,0 +1,227 @@
+package rpcapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/server"
+)
+
+func TestErrStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   int
+	}{
+		{errUserExists, http.StatusConflict, USER_EXISTS},
+		{syscall.EADDRINUSE, http.StatusConflict, PORT_IN_USE},
+		{server.ErrDuplicateKey, http.StatusConflict, PORT_IN_USE},
+		{errUserNonExists, http.StatusNotFound, USER_NON_EXISTS},
+		{errUserExpired, http.StatusGone, USER_EXPIRED},
+		{errReplayed, http.StatusUnauthorized, AUTH_ERROR},
+		{R.ErrBadSign, http.StatusUnauthorized, AUTH_ERROR},
+		{&storeError{errors.New("down")}, http.StatusServiceUnavailable, REDIS_ERROR},
+		{&net.OpError{Op: "listen", Err: errors.New("denied")}, http.StatusInternalServerError, LISTEN_ERROR},
+		{&filterError{errors.New("status 500")}, http.StatusBadGateway, FILTER_ERROR},
+		{server.ErrCipherNotSupported, http.StatusBadRequest, INVALID_CIPHER},
+		{server.KeySizeError(16), http.StatusBadRequest, INVALID_PASSWORD},
+		{errBadSchedule, http.StatusBadRequest, PARAMS_ERROR},
+		{fmt.Errorf("wrapped: %w", errUserNonExists), http.StatusNotFound, USER_NON_EXISTS},
+	}
+	for _, tt := range tests {
+		status, code := errStatus(tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("errStatus(%v) = %d, %d, want %d, %d", tt.err, status, code, tt.status, tt.code)
+		}
+	}
+}
+
+// restCall sends a REST call, signed with the key id if secret is set.
+func restCall(t *testing.T, url, method, path, rpc string, args R.Signed, secret string) (int, []byte) {
+	t.Helper()
+	if secret != "" {
+		if err := R.Sign(rpc, args, "id", secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, url+path, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := args.Credentials()
+	req.Header.Set(headerAccessID, a.AccessID)
+	req.Header.Set(headerAccessToken, a.AccessToken)
+	req.Header.Set(headerTimestamp, strconv.FormatInt(a.Timestamp, 10))
+	req.Header.Set(headerSign, a.Sign)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	return resp.StatusCode, body.Bytes()
+}
+
+func TestREST(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	do := func(method, path, rpc string, args R.Signed) (int, []byte) {
+		return restCall(t, srv.URL, method, path, rpc, args, "")
+	}
+	expect := func(what string, status int, body []byte, wantStatus, wantCode int) {
+		t.Helper()
+		if status != wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", what, status, wantStatus, body)
+			return
+		}
+		var reply R.CallReply
+		if wantCode >= 0 && (json.Unmarshal(body, &reply) != nil || reply.ErrCode != wantCode) {
+			t.Errorf("%s: %s, want ErrCode %d", what, body, wantCode)
+		}
+	}
+
+	add := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	status, body := do("POST", "/api/users", "UserRpc.AddUser", add)
+	expect("add", status, body, http.StatusCreated, NO_ERROR)
+	status, body = do("POST", "/api/users", "UserRpc.AddUser", add)
+	expect("add again", status, body, http.StatusConflict, USER_EXISTS)
+
+	status, body = do("GET", "/api/users", "UserRpc.GetUser", &R.CommonArgs{})
+	var traffic R.TrafficReply
+	if status != http.StatusOK || json.Unmarshal(body, &traffic) != nil || len(traffic) != 1 {
+		t.Errorf("list: %d %s", status, body)
+	}
+	status, body = do("GET", "/api/users/a", "UserRpc.GetUser", &R.CommonArgs{})
+	if status != http.StatusOK || json.Unmarshal(body, &traffic) != nil || len(traffic) != 1 {
+		t.Errorf("get: %d %s", status, body)
+	}
+	status, body = do("GET", "/api/users/a/usage?period=day&from=abc", "UserRpc.GetUsage", &R.UsageArgs{})
+	expect("bad query", status, body, http.StatusBadRequest, PARAMS_ERROR)
+	status, body = do("GET", "/api/users/a/usage?period=day", "UserRpc.GetUsage", &R.UsageArgs{})
+	expect("usage", status, body, http.StatusOK, -1)
+
+	status, body = do("PATCH", "/api/users/a", "UserRpc.Modify", &R.CommonArgs{Cipher: "CHACHA20-IETF-POLY1305"})
+	expect("modify", status, body, http.StatusOK, NO_ERROR)
+	status, body = do("PATCH", "/api/users/a", "UserRpc.Modify", &R.CommonArgs{Cipher: "bogus"})
+	expect("modify cipher", status, body, http.StatusBadRequest, INVALID_CIPHER)
+	status, body = do("PUT", "/api/users/a/quota", "UserRpc.SetQuota", &R.QuotaArgs{Quota: 1 << 30})
+	expect("quota", status, body, http.StatusOK, NO_ERROR)
+	status, body = do("POST", "/api/users/a/stop", "UserRpc.StopUser", &R.CommonArgs{})
+	expect("stop", status, body, http.StatusOK, NO_ERROR)
+	status, body = do("POST", "/api/users/a/start", "UserRpc.StartUser", &R.CommonArgs{})
+	expect("start", status, body, http.StatusOK, NO_ERROR)
+	status, body = do("DELETE", "/api/users/a", "UserRpc.DeleteUser", &R.CommonArgs{})
+	expect("delete", status, body, http.StatusOK, NO_ERROR)
+	status, body = do("DELETE", "/api/users/a", "UserRpc.DeleteUser", &R.CommonArgs{})
+	expect("delete again", status, body, http.StatusNotFound, USER_NON_EXISTS)
+
+	status, body = do("PUT", "/api/users", "", &R.NoArgs{})
+	expect("method", status, body, http.StatusMethodNotAllowed, PARAMS_ERROR)
+	status, body = do("GET", "/api/nothing", "", &R.NoArgs{})
+	expect("path", status, body, http.StatusNotFound, PARAMS_ERROR)
+
+	req, err := http.NewRequest("PUT", srv.URL+"/api/users/a/quota", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad body: status %d", resp.StatusCode)
+	}
+}
+
+func TestRESTAuth(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	r.SetAuthKeys(map[string]string{"id": "secret"}, time.Minute)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	if status, body := restCall(t, srv.URL, "POST", "/api/users", "UserRpc.AddUser", args, ""); status != http.StatusUnauthorized {
+		t.Errorf("unsigned: %d %s", status, body)
+	}
+	if status, body := restCall(t, srv.URL, "POST", "/api/users", "UserRpc.AddUser", args, "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("bad secret: %d %s", status, body)
+	}
+	if status, body := restCall(t, srv.URL, "POST", "/api/users", "UserRpc.AddUser", args, "secret"); status != http.StatusCreated {
+		t.Errorf("signed: %d %s", status, body)
+	}
+	// the name in the path is signed
+	stop := &R.CommonArgs{Name: "a"}
+	if status, body := restCall(t, srv.URL, "POST", "/api/users/a/stop", "UserRpc.StopUser", stop, "secret"); status != http.StatusOK {
+		t.Errorf("signed stop: %d %s", status, body)
+	}
+	stop = &R.CommonArgs{Name: "b"}
+	if status, body := restCall(t, srv.URL, "POST", "/api/users/a/start", "UserRpc.StartUser", stop, "secret"); status != http.StatusUnauthorized {
+		t.Errorf("signed for another user: %d %s", status, body)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv := httptest.NewServer(&UserRpc{})
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var doc struct {
+		OpenAPI string
+		Paths   map[string]map[string]struct {
+			OperationID string
+			Responses   map[string]interface{}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI == "" {
+		t.Error("no openapi version")
+	}
+	ids := map[string]bool{}
+	for _, rt := range routes {
+		op, ok := doc.Paths["/api"+rt.path][strings.ToLower(rt.method)]
+		if !ok {
+			t.Errorf("%s %s missing", rt.method, rt.path)
+			continue
+		}
+		if op.Responses[strconv.Itoa(rt.status)] == nil {
+			t.Errorf("%s %s: no %d response", rt.method, rt.path, rt.status)
+		}
+		if ids[op.OperationID] {
+			t.Errorf("%s %s: operationId %q used twice", rt.method, rt.path, op.OperationID)
+		}
+		ids[op.OperationID] = true
+	}
+	n := 0
+	for _, item := range doc.Paths {
+		n += len(item)
+	}
+	if n != len(routes) {
+		t.Errorf("%d operations, want %d", n, len(routes))
+	}
+}
@@ -1,359 +1,631 @@
-package rpcapi
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"net/rpc"
-	"strconv"
-	"time"
-
-	filter "github.com/BishiNET/ss-server/domainfilter"
-	R "github.com/BishiNET/ss-server/rpcinterface"
-	u "github.com/BishiNET/ss-server/usermap"
-	"github.com/go-redis/redis/v8"
-	reuse "github.com/libp2p/go-reuseport"
-)
-
-var (
-	ctx = context.Background()
-)
-
-const (
-	NO_ERROR = iota
-	USER_EXISTS
-	USER_NON_EXISTS
-	PARAMS_ERROR
-)
-
-type UserRpc struct {
-	Users u.UserMap
-	rdb   *redis.Client
-}
-
-func mustPing(rdb *redis.Client) {
-	_ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	_, err := rdb.Ping(_ctx).Result()
-	if err != nil {
-		log.Fatalln(err)
-	}
-}
-func New(rpcAddr string, redisOpts ...string) *UserRpc {
-	var opts *redis.Options
-	switch len(redisOpts) {
-	case 1:
-		opts = &redis.Options{
-			Addr: redisOpts[0],
-		}
-	case 2:
-		opts = &redis.Options{
-			Addr:     redisOpts[0],
-			Password: redisOpts[1],
-		}
-	case 3:
-		db, _ := strconv.Atoi(redisOpts[2])
-		opts = &redis.Options{
-			Addr:     redisOpts[0],
-			Password: redisOpts[1],
-			DB:       db,
-		}
-	default:
-		opts = &redis.Options{
-			Addr:     "localhost:6379",
-			Password: "",
-		}
-	}
-	rdb := redis.NewClient(opts)
-	mustPing(rdb)
-
-	_uRpc := &UserRpc{
-		Users: u.NewMap(),
-		rdb:   rdb,
-	}
-	rpc.Register(_uRpc)
-	rpc.HandleHTTP()
-	l, e := reuse.Listen("tcp", rpcAddr)
-	if e != nil {
-		log.Fatal("listen error:", e)
-	}
-	go http.Serve(l, nil)
-	return _uRpc
-}
-
-func (r *UserRpc) RedisClose() {
-	r.rdb.Close()
-}
-
-func (r *UserRpc) GetUserInfo(name string) (string, string, string, error) {
-	cipher, err1 := r.rdb.HGet(ctx, name, "cipher").Result()
-	password, err2 := r.rdb.HGet(ctx, name, "password").Result()
-	port, err3 := r.rdb.HGet(ctx, name, "port").Result()
-	if err1 != nil || err2 != nil || err3 != nil {
-		return "", "", "", fmt.Errorf("user doesn't exist")
-	}
-	return cipher, password, port, nil
-}
-
-func (r *UserRpc) GetAll() R.TrafficReply {
-	_users := R.TrafficReply{}
-	executor := func(name string, traffic uint64, usedtime int64) {
-		_users[name] = R.SingleTrafficReply{
-			Traffic:  traffic,
-			UsedTime: usedtime,
-		}
-	}
-	r.Users.GetAll(executor)
-	return _users
-}
-
-func (r *UserRpc) FastRestore() {
-	userSlices, err := r.rdb.Keys(ctx, "*").Result()
-	if err != nil {
-		log.Fatal(err)
-	}
-	for _, name := range userSlices {
-		log.Println("Restart "+name, r.startUser(name))
-	}
-
-}
-
-func (r *UserRpc) startUser(name string) error {
-	cipher, password, port, err := r.GetUserInfo(name)
-	if err != nil {
-		//log.Println(err)
-		r.rdb.Del(ctx, name)
-		return err
-	}
-	traffic, err := r.rdb.HGet(ctx, name, "traffic").Uint64()
-	if err != nil {
-		traffic = 0
-	}
-	time, err := r.rdb.HGet(ctx, name, "time").Int64()
-	if err != nil {
-		time = 0
-	}
-
-	err = r.Users.AddUser(name, cipher, password, port)
-	if err != nil {
-		//Invalid situation
-		//so remove the user
-		r.rdb.Del(ctx, name)
-		return fmt.Errorf("params error")
-	}
-	r.Users.SetUser(name, traffic, time)
-	return nil
-}
-func (r *UserRpc) Restore(args *R.NoArgs, reply *R.CallReply) error {
-	userSlices, err := r.rdb.Keys(ctx, "*").Result()
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: "PARAMS ERROR",
-		}
-		return fmt.Errorf("params error")
-	}
-	for _, name := range userSlices {
-		if err != nil {
-			log.Println("Restart"+name, r.startUser(name))
-		} else {
-			err = r.startUser(name)
-		}
-	}
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: err.Error(),
-		}
-		return err
-	}
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-func (r *UserRpc) AddUser(args *R.NewUserArgs, reply *R.CallReply) error {
-	if r.Users.Exists(args.Name) {
-		log.Println("User has already existed")
-		reply = &R.CallReply{
-			ErrCode:   USER_EXISTS,
-			ErrReason: "user has already existed",
-		}
-		return fmt.Errorf("user has already existed")
-	}
-	r.rdb.HSet(ctx, args.Name, "cipher", args.Cipher)
-	r.rdb.HSet(ctx, args.Name, "password", args.Password)
-	r.rdb.HSet(ctx, args.Name, "port", args.Port)
-	err := r.Users.AddUser(args.Name, args.Cipher, args.Password, args.Port)
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: "PARAMS ERROR",
-		}
-		r.rdb.Del(ctx, args.Name)
-		return fmt.Errorf("params error")
-	}
-	log.Println("Add User: " + args.Name)
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-func (r *UserRpc) StartUser(args *R.CommonArgs, reply *R.CallReply) error {
-	err := r.startUser(args.Name)
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: err.Error(),
-		}
-		return err
-	}
-	log.Println("Start User" + args.Name)
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-func (r *UserRpc) StopUser(args *R.CommonArgs, reply *R.CallReply) error {
-	if !r.Users.Exists(args.Name) {
-		reply = &R.CallReply{
-			ErrCode:   USER_NON_EXISTS,
-			ErrReason: "user doesn't exist",
-		}
-		return fmt.Errorf("user doesn't exist")
-	}
-	traffic, time := r.Users.GetUser(args.Name)
-	r.rdb.HSet(ctx, args.Name, "traffic", traffic)
-	r.rdb.HSet(ctx, args.Name, "time", time)
-	r.Users.DeleteUser(args.Name)
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	log.Println("Stop User" + args.Name)
-	return nil
-}
-
-func (r *UserRpc) DeleteUser(args *R.CommonArgs, reply *R.CallReply) error {
-	if !r.Users.Exists(args.Name) {
-		reply = &R.CallReply{
-			ErrCode:   USER_NON_EXISTS,
-			ErrReason: "user doesn't exist",
-		}
-		return fmt.Errorf("user doesn't exist")
-	}
-	r.rdb.Del(ctx, args.Name)
-	r.Users.DeleteUser(args.Name)
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	log.Println("Delete User" + args.Name)
-	return nil
-}
-
-func (r *UserRpc) Modify(args *R.CommonArgs, reply *R.CallReply) error {
-	if !r.Users.Exists(args.Name) {
-		reply = &R.CallReply{
-			ErrCode:   USER_NON_EXISTS,
-			ErrReason: "user doesn't exist",
-		}
-		return fmt.Errorf("user doesn't exist")
-	}
-	cipher, password, port, err := r.GetUserInfo(args.Name)
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: "PARAMS ERROR",
-		}
-		log.Println(err)
-		return err
-	}
-
-	if args.Password != "" {
-		if args.Password != password {
-			password = args.Password
-			r.rdb.HSet(ctx, args.Name, "password", args.Password)
-		}
-
-	}
-	if args.Cipher != "" {
-		if args.Cipher != cipher {
-			cipher = args.Cipher
-			r.rdb.HSet(ctx, args.Name, "cipher", args.Cipher)
-		}
-	}
-
-	if args.Password == password && args.Cipher == cipher {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: "nothing is modfied",
-		}
-		//log.Println(err)
-		return fmt.Errorf("nothing is modfied")
-	}
-
-	tmp := r.Users[args.Name]
-	err = r.Users.AddUser(args.Name, cipher, password, port)
-	if err != nil {
-		reply = &R.CallReply{
-			ErrCode:   PARAMS_ERROR,
-			ErrReason: "PARAMS ERROR",
-		}
-		return fmt.Errorf("params error")
-	}
-	traffic, time := tmp.Get()
-	r.Users.SetUser(args.Name, traffic, time)
-	tmp.Shutdown()
-	tmp = nil
-	log.Println("User Change password" + args.Name)
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-
-func (r *UserRpc) GetUser(args *R.CommonArgs, reply *R.TrafficReply) error {
-	if args.Name != "" {
-		if !r.Users.Exists(args.Name) {
-			return fmt.Errorf("user doesn't exist")
-		}
-		ut := R.TrafficReply{}
-		traffic, time := r.Users.GetUser(args.Name)
-		ut[args.Name] = R.SingleTrafficReply{
-			Traffic:  traffic,
-			UsedTime: time,
-		}
-		//log.Println(traffic, time)
-		*reply = ut
-		return nil
-	}
-	ut := r.GetAll()
-	*reply = ut
-	return nil
-}
-
-func (r *UserRpc) ResetAll(args *R.NoArgs, reply *R.CallReply) error {
-	r.Users.ResetAll()
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-
-func (r *UserRpc) UpgradeFilter(args *R.NoArgs, reply *R.CallReply) error {
-	filter.UpgradeFilter()
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
-
-func (r *UserRpc) AddFilter(args *R.Filters, reply *R.CallReply) error {
-	if args.URL != nil {
-		filter.AddFilter(args.URL)
-	}
-	reply = &R.CallReply{
-		ErrCode: NO_ERROR,
-	}
-	return nil
-}
+package rpcapi
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"time"
+
+	filter "github.com/BishiNET/ss-server/domainfilter"
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/server"
+	"github.com/BishiNET/ss-server/store"
+	u "github.com/BishiNET/ss-server/usermap"
+	reuse "github.com/libp2p/go-reuseport"
+	"google.golang.org/grpc"
+)
+
+const (
+	NO_ERROR = iota
+	USER_EXISTS
+	USER_NON_EXISTS
+	PARAMS_ERROR
+	AUTH_ERROR
+	REDIS_ERROR
+	LISTEN_ERROR
+	INVALID_CIPHER
+	PORT_IN_USE
+	USER_EXPIRED
+	FILTER_ERROR
+	INVALID_PASSWORD
+)
+
+type UserRpc struct {
+	Users u.UserMap
+	st    store.Store
+	// cluster is set if the store is shared with other nodes
+	cluster store.Cluster
+	ports   map[string]string
+	l       net.Listener
+	restL   net.Listener
+	grpcS   *grpc.Server
+	*flusher
+	reload reloadState
+	auth   authState
+	expiry userTimers
+	resets userTimers
+	locks  userLocks
+}
+
+// New serves the net/rpc API on rpcAddr, keeping the users in st.
+func New(rpcAddr string, st store.Store) *UserRpc {
+	_uRpc := &UserRpc{
+		Users:   u.NewMap(),
+		st:      st,
+		flusher: newFlusher(),
+	}
+	rpc.Register(_uRpc)
+	rpc.HandleHTTP()
+	l, e := reuse.Listen("tcp", rpcAddr)
+	if e != nil {
+		log.Fatal("listen error:", e)
+	}
+	_uRpc.l = l
+	go http.Serve(l, nil)
+	return _uRpc
+}
+
+func (r *UserRpc) StoreClose() {
+	r.st.Close()
+}
+
+// Close stops accepting RPC, REST and gRPC calls.
+func (r *UserRpc) Close() error {
+	if r.restL != nil {
+		r.restL.Close()
+	}
+	if r.grpcS != nil {
+		r.grpcS.Stop()
+	}
+	return r.l.Close()
+}
+
+func (r *UserRpc) GetUserInfo(name string) (string, string, string, error) {
+	su, err := r.st.Get(name)
+	if err != nil {
+		return "", "", "", storeErr(err)
+	}
+	return su.Cipher, su.Password, su.Port, nil
+}
+
+// updateRecord changes the stored settings of a user.
+func (r *UserRpc) updateRecord(name string, f func(*store.Record)) error {
+	su, err := r.st.Get(name)
+	if err != nil {
+		return storeErr(err)
+	}
+	f(&su.Record)
+	return storeErr(r.st.Put(name, su.Record))
+}
+
+func trafficReply(traffic uint64, usedtime int64, detail server.TrafficDetail) R.SingleTrafficReply {
+	return R.SingleTrafficReply{
+		Traffic:     traffic,
+		UsedTime:    usedtime,
+		Upload:      detail.Upload(),
+		Download:    detail.Download(),
+		TCPUpload:   detail.TCPUpload,
+		TCPDownload: detail.TCPDownload,
+		UDPUpload:   detail.UDPUpload,
+		UDPDownload: detail.UDPDownload,
+	}
+}
+
+func (r *UserRpc) GetAll() R.TrafficReply {
+	users := map[string]counters{}
+	executor := func(name string, traffic uint64, usedtime int64, detail server.TrafficDetail) {
+		users[name] = counters{traffic, usedtime, detail}
+	}
+	r.Users.GetAll(executor)
+	_users := R.TrafficReply{}
+	for name, c := range users {
+		_users[name] = r.total(name, c)
+	}
+	return _users
+}
+
+func (r *UserRpc) FastRestore() {
+	users, err := r.st.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now()
+	for name, su := range users {
+		if expired(su.Record, now) {
+			log.Println("Skip expired " + name)
+			continue
+		}
+		log.Println("Restart "+name, r.startHeld(name, su))
+	}
+}
+
+// startUser starts a stored user. Records which can't be started are
+// kept, Modify may fix them. The lock of the user is held by the caller.
+func (r *UserRpc) startUser(name string, su *store.User) error {
+	if r.Users.Exists(name) {
+		return errUserExists
+	}
+	now := time.Now()
+	if expired(su.Record, now) {
+		return errUserExpired
+	}
+	// catch up with the reset missed while stopped
+	if err := r.resetDue(name, su, now); err != nil {
+		log.Println("Reset "+name, err)
+	}
+	err := r.Users.AddUser(name, su.Cipher, su.Password, r.userPort(name, su.Port))
+	if err != nil {
+		log.Println("Start "+name, err)
+		return err
+	}
+	r.Users.SetUser(name, su.Traffic, su.Time)
+	r.Users.SetUserDetail(name, server.TrafficDetail{
+		TCPUpload:   su.TCPUp,
+		TCPDownload: su.TCPDown,
+		UDPUpload:   su.UDPUp,
+		UDPDownload: su.UDPDown,
+	})
+	r.Users.SetUserQuota(name, su.Quota)
+	r.pullRemote(name)
+	upLimit, downLimit := r.rateLimit(su.UpLimit, su.DownLimit)
+	r.Users.SetUserRateLimit(name, upLimit, downLimit)
+	r.markFlushed(name)
+	r.scheduleExpiry(name, su.ExpireAt)
+	r.scheduleReset(name, su.NextReset)
+	return nil
+}
+
+// startHeld starts a stored user, holding its lock.
+func (r *UserRpc) startHeld(name string, su *store.User) error {
+	defer r.locks.hold(name)()
+	return r.startUser(name, su)
+}
+
+// stopLocal stops a running user on this node, with its lock held.
+func (r *UserRpc) stopLocal(name string) {
+	r.scheduleExpiry(name, 0)
+	r.scheduleReset(name, 0)
+	r.forgetFlushed(name)
+	r.Users.DeleteUser(name)
+}
+
+func (r *UserRpc) Restore(args *R.NoArgs, reply *R.CallReply) error {
+	return callResult(reply, r.restore(args))
+}
+
+func (r *UserRpc) restore(args *R.NoArgs) error {
+	if err := r.authorize("UserRpc.Restore", args); err != nil {
+		return err
+	}
+	users, err := r.st.Load()
+	if err != nil {
+		return storeErr(err)
+	}
+	// the first failure is reported, the others are logged
+	now := time.Now()
+	for name, su := range users {
+		if r.Users.Exists(name) || expired(su.Record, now) {
+			continue
+		}
+		if err != nil {
+			log.Println("Restart "+name, r.startHeld(name, su))
+		} else {
+			err = r.startHeld(name, su)
+		}
+	}
+	return err
+}
+
+func (r *UserRpc) AddUser(args *R.NewUserArgs, reply *R.CallReply) error {
+	return callResult(reply, r.addUser(args))
+}
+
+func (r *UserRpc) addUser(args *R.NewUserArgs) error {
+	if err := r.authorize("UserRpc.AddUser", args); err != nil {
+		return err
+	}
+	defer r.locks.hold(args.Name)()
+	if r.Users.Exists(args.Name) {
+		log.Println("User has already existed")
+		return errUserExists
+	}
+	if args.ExpireAt < 0 {
+		return errBadExpiry
+	}
+	if expired(store.Record{ExpireAt: args.ExpireAt}, time.Now()) {
+		return errUserExpired
+	}
+	// nothing is recorded unless the user is listening
+	err := r.Users.AddUser(args.Name, args.Cipher, args.Password, r.userPort(args.Name, args.Port))
+	if err != nil {
+		log.Println("Add User: "+args.Name, err)
+		return err
+	}
+	err = r.st.Put(args.Name, store.Record{
+		Cipher:    args.Cipher,
+		Password:  args.Password,
+		Port:      args.Port,
+		Quota:     args.Quota,
+		UpLimit:   args.UpLimit,
+		DownLimit: args.DownLimit,
+		ExpireAt:  args.ExpireAt,
+	})
+	if err != nil {
+		r.Users.DeleteUser(args.Name)
+		return storeErr(err)
+	}
+	r.Users.SetUserQuota(args.Name, args.Quota)
+	upLimit, downLimit := r.rateLimit(args.UpLimit, args.DownLimit)
+	r.Users.SetUserRateLimit(args.Name, upLimit, downLimit)
+	// counters left by a stopped user of the same name start over
+	if err := r.resetFlushed(args.Name); err != nil {
+		log.Println("Reset "+args.Name, err)
+	}
+	r.scheduleExpiry(args.Name, args.ExpireAt)
+	r.publish(store.EventAdd, args.Name)
+	log.Println("Add User: " + args.Name)
+	return nil
+}
+
+func (r *UserRpc) StartUser(args *R.CommonArgs, reply *R.CallReply) error {
+	return callResult(reply, r.startUserCall(args))
+}
+
+func (r *UserRpc) startUserCall(args *R.CommonArgs) error {
+	if err := r.authorize("UserRpc.StartUser", args); err != nil {
+		return err
+	}
+	defer r.locks.hold(args.Name)()
+	if err := r.startStored(args.Name); err != nil {
+		return err
+	}
+	r.publish(store.EventStart, args.Name)
+	log.Println("Start User" + args.Name)
+	return nil
+}
+
+// startStored starts a user as stored.
+func (r *UserRpc) startStored(name string) error {
+	if r.Users.Exists(name) {
+		return errUserExists
+	}
+	su, err := r.st.Get(name)
+	if err != nil {
+		return storeErr(err)
+	}
+	return r.startUser(name, su)
+}
+
+func (r *UserRpc) StopUser(args *R.CommonArgs, reply *R.CallReply) error {
+	return callResult(reply, r.stopUser(args))
+}
+
+func (r *UserRpc) stopUser(args *R.CommonArgs) error {
+	if err := r.authorize("UserRpc.StopUser", args); err != nil {
+		return err
+	}
+	defer r.locks.hold(args.Name)()
+	if !r.Users.Exists(args.Name) {
+		return errUserNonExists
+	}
+	// keep the user running rather than losing its traffic
+	if err := r.flushUser(args.Name); err != nil {
+		return storeErr(err)
+	}
+	r.stopLocal(args.Name)
+	r.publish(store.EventStop, args.Name)
+	log.Println("Stop User" + args.Name)
+	return nil
+}
+
+func (r *UserRpc) DeleteUser(args *R.CommonArgs, reply *R.CallReply) error {
+	return callResult(reply, r.deleteUser(args))
+}
+
+func (r *UserRpc) deleteUser(args *R.CommonArgs) error {
+	if err := r.authorize("UserRpc.DeleteUser", args); err != nil {
+		return err
+	}
+	defer r.locks.hold(args.Name)()
+	if !r.Users.Exists(args.Name) {
+		return errUserNonExists
+	}
+	if err := r.st.Delete(args.Name); err != nil {
+		return storeErr(err)
+	}
+	r.stopLocal(args.Name)
+	r.publish(store.EventDelete, args.Name)
+	log.Println("Delete User" + args.Name)
+	return nil
+}
+
+func (r *UserRpc) Modify(args *R.CommonArgs, reply *R.CallReply) error {
+	return callResult(reply, r.modify(args))
+}
+
+func (r *UserRpc) modify(args *R.CommonArgs) error {
+	if err := r.authorize("UserRpc.Modify", args); err != nil {
+		return err
+	}
+	defer r.locks.hold(args.Name)()
+	su, err := r.st.Get(args.Name)
+	if err != nil {
+		return storeErr(err)
+	}
+	rec := su.Record
+	if args.Password != "" {
+		rec.Password = args.Password
+	}
+	if args.Cipher != "" {
+		rec.Cipher = args.Cipher
+	}
+	switch {
+	case args.ExpireAt > 0:
+		rec.ExpireAt = args.ExpireAt
+	case args.ExpireAt < 0:
+		rec.ExpireAt = 0
+	}
+	if rec == su.Record {
+		return errNotModified
+	}
+	// a stopped user only has its record changed
+	running := r.Users.Exists(args.Name)
+	op := store.EventUpdate
+	if rec.Password != su.Password || rec.Cipher != su.Cipher {
+		op = store.EventModify
+		// a stopped user would fail on the next start instead
+		if err := server.CheckCipher(rec.Cipher, rec.Password); err != nil {
+			return err
+		}
+		if running {
+			if err := r.replaceUser(args.Name, rec.Cipher, rec.Password, rec.Port); err != nil {
+				return err
+			}
+		}
+	}
+	if rec.ExpireAt != su.ExpireAt {
+		rec.Expired = expired(store.Record{ExpireAt: rec.ExpireAt}, time.Now())
+		if running {
+			r.scheduleExpiry(args.Name, rec.ExpireAt)
+		}
+	}
+	if err := r.st.Put(args.Name, rec); err != nil {
+		log.Println("Modify "+args.Name, err)
+		return storeErr(err)
+	}
+	r.publish(op, args.Name)
+	log.Println("User Change password" + args.Name)
+	return nil
+}
+
+// replaceUser restarts a running user with new credentials, keeping its
+// counters and limits.
+func (r *UserRpc) replaceUser(name, cipher, password, port string) error {
+	tmp := r.Users.Get(name)
+	if tmp == nil {
+		return errUserNonExists
+	}
+	err := r.Users.AddUser(name, cipher, password, r.userPort(name, port))
+	if err != nil {
+		return err
+	}
+	traffic, time := tmp.Get()
+	r.Users.SetUser(name, traffic, time)
+	r.Users.SetUserDetail(name, tmp.GetDetail())
+	r.Users.SetUserQuota(name, tmp.GetQuota())
+	r.Users.SetUserRemote(name, tmp.GetRemote())
+	upLimit, downLimit := tmp.GetRateLimit()
+	r.Users.SetUserRateLimit(name, upLimit, downLimit)
+	tmp.Shutdown()
+	return nil
+}
+
+func (r *UserRpc) SetQuota(args *R.QuotaArgs, reply *R.CallReply) error {
+	return callResult(reply, r.setQuota(args))
+}
+
+func (r *UserRpc) setQuota(args *R.QuotaArgs) error {
+	if err := r.authorize("UserRpc.SetQuota", args); err != nil {
+		return err
+	}
+	if !r.Users.Exists(args.Name) {
+		return errUserNonExists
+	}
+	err := r.updateRecord(args.Name, func(rec *store.Record) {
+		rec.Quota = args.Quota
+	})
+	if err != nil {
+		return err
+	}
+	r.Users.SetUserQuota(args.Name, args.Quota)
+	r.pullRemote(args.Name)
+	r.publish(store.EventUpdate, args.Name)
+	log.Println("Set Quota" + args.Name)
+	return nil
+}
+
+func (r *UserRpc) SetRateLimit(args *R.RateLimitArgs, reply *R.CallReply) error {
+	return callResult(reply, r.setRateLimit(args))
+}
+
+func (r *UserRpc) setRateLimit(args *R.RateLimitArgs) error {
+	if err := r.authorize("UserRpc.SetRateLimit", args); err != nil {
+		return err
+	}
+	if !r.Users.Exists(args.Name) {
+		return errUserNonExists
+	}
+	err := r.updateRecord(args.Name, func(rec *store.Record) {
+		rec.UpLimit, rec.DownLimit = args.UpLimit, args.DownLimit
+	})
+	if err != nil {
+		return err
+	}
+	upLimit, downLimit := r.rateLimit(args.UpLimit, args.DownLimit)
+	r.Users.SetUserRateLimit(args.Name, upLimit, downLimit)
+	r.publish(store.EventUpdate, args.Name)
+	log.Println("Set Rate Limit" + args.Name)
+	return nil
+}
+
+// GetUser has no error code in its reply, failures are returned as errors.
+func (r *UserRpc) GetUser(args *R.CommonArgs, reply *R.TrafficReply) error {
+	ut, err := r.getUser(args)
+	if err != nil {
+		return err
+	}
+	*reply = ut
+	return nil
+}
+
+func (r *UserRpc) getUser(args *R.CommonArgs) (R.TrafficReply, error) {
+	if err := r.authorize("UserRpc.GetUser", args); err != nil {
+		return nil, err
+	}
+	if args.Name != "" {
+		if !r.Users.Exists(args.Name) {
+			return nil, errUserNonExists
+		}
+		ut := R.TrafficReply{}
+		ut[args.Name] = r.total(args.Name, r.snapshot(args.Name))
+		//log.Println(traffic, time)
+		return ut, nil
+	}
+	return r.GetAll(), nil
+}
+
+func (r *UserRpc) ResetAll(args *R.NoArgs, reply *R.CallReply) error {
+	return callResult(reply, r.resetAll(args))
+}
+
+func (r *UserRpc) resetAll(args *R.NoArgs) error {
+	if err := r.authorize("UserRpc.ResetAll", args); err != nil {
+		return err
+	}
+	if err := r.resetUsers(); err != nil {
+		return err
+	}
+	r.publish(store.EventReset, "")
+	return nil
+}
+
+func (r *UserRpc) runningUsers() map[string]struct{} {
+	users := map[string]struct{}{}
+	r.Users.GetAll(func(name string, _ uint64, _ int64, _ server.TrafficDetail) {
+		users[name] = struct{}{}
+	})
+	return users
+}
+
+// resetLocal resets the running users after another node has reset
+// the store.
+func (r *UserRpc) resetLocal() {
+	r.resetRunning(false)
+}
+
+// resetUsers resets the running users, the first failure is returned.
+func (r *UserRpc) resetUsers() error {
+	return r.resetRunning(true)
+}
+
+func (r *UserRpc) UpgradeFilter(args *R.NoArgs, reply *R.CallReply) error {
+	return callResult(reply, r.upgradeFilter(args))
+}
+
+func (r *UserRpc) upgradeFilter(args *R.NoArgs) error {
+	if err := r.authorize("UserRpc.UpgradeFilter", args); err != nil {
+		return err
+	}
+	if err := filter.UpgradeFilter(); err != nil {
+		return &filterError{err}
+	}
+	return nil
+}
+
+func (r *UserRpc) AddFilter(args *R.Filters, reply *R.FilterReply) error {
+	sources, err := r.addFilter(args)
+	*reply = R.FilterReply{ErrCode: errCode(err), Sources: sources}
+	if err != nil {
+		reply.ErrReason = err.Error()
+	}
+	return nil
+}
+
+func (r *UserRpc) addFilter(args *R.Filters) ([]R.FilterSource, error) {
+	if err := r.authorize("UserRpc.AddFilter", args); err != nil {
+		return nil, err
+	}
+	for _, v := range args.URL {
+		if _, _, err := filter.ParseSource(v); err != nil {
+			return nil, err
+		}
+	}
+	if args.URL == nil {
+		return nil, nil
+	}
+	reports, err := filter.AddFilter(args.URL)
+	if err != nil {
+		err = &filterError{err}
+	}
+	var sources []R.FilterSource
+	for _, rep := range reports {
+		sources = append(sources, filterSource(rep))
+	}
+	return sources, err
+}
+
+func filterSource(rep filter.Report) R.FilterSource {
+	src := R.FilterSource{
+		URL:      rep.Source,
+		Format:   string(rep.Format),
+		Accepted: rep.Accepted,
+		Rejected: rep.Rejected,
+		Cached:   rep.Cached,
+	}
+	if rep.Err != nil {
+		src.Error = rep.Err.Error()
+	}
+	return src
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (r *UserRpc) FilterStatus(args *R.NoArgs, reply *R.FilterStatusReply) error {
+	st, err := r.filterStatus(args)
+	st.ErrCode = errCode(err)
+	if err != nil {
+		st.ErrReason = err.Error()
+	}
+	*reply = st
+	return nil
+}
+
+func (r *UserRpc) filterStatus(args *R.NoArgs) (R.FilterStatusReply, error) {
+	if err := r.authorize("UserRpc.FilterStatus", args); err != nil {
+		return R.FilterStatusReply{}, err
+	}
+	st := filter.GetStatus()
+	reply := R.FilterStatusReply{
+		Backend:         st.Backend,
+		Domains:         st.Domains,
+		Memory:          st.Memory,
+		Built:           unixTime(st.Built),
+		Checked:         unixTime(st.Checked),
+		RefreshInterval: int64(st.Interval / time.Second),
+	}
+	if st.Err != nil {
+		reply.Error = st.Err.Error()
+	}
+	for _, v := range st.Sources {
+		src := filterSource(v.Report)
+		src.Checked, src.Updated = unixTime(v.Checked), unixTime(v.Updated)
+		reply.Sources = append(reply.Sources, src)
+	}
+	return reply, nil
+}
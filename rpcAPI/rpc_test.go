@@ -0,0 +1,269 @@
+package rpcapi
+
+import (
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/store"
+	u "github.com/BishiNET/ss-server/usermap"
+)
+
+func init() {
+	u.SetBindIP("127.0.0.1")
+}
+
+// testRpc serves a UserRpc on the file store in dir over a loopback
+// net/rpc connection.
+func testRpc(t *testing.T, dir string) (*UserRpc, *rpc.Client) {
+	st, err := store.NewFile(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &UserRpc{
+		Users:   u.NewMap(),
+		st:      st,
+		flusher: newFlusher(),
+	}
+	r.SetAuthKeys(nil, time.Minute)
+	srv := rpc.NewServer()
+	if err := srv.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Accept(l)
+	c, err := rpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		l.Close()
+		for name := range r.runningUsers() {
+			r.stopLocal(name)
+		}
+	})
+	return r, c
+}
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func call(t *testing.T, c *rpc.Client, method string, args interface{}) R.CallReply {
+	var reply R.CallReply
+	if err := c.Call(method, args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func expectCode(t *testing.T, what string, reply R.CallReply, code int) {
+	t.Helper()
+	if reply.ErrCode != code {
+		t.Errorf("%s: ErrCode %d (%s), want %d", what, reply.ErrCode, reply.ErrReason, code)
+	}
+}
+
+func TestErrCode(t *testing.T) {
+	_, c := testRpc(t, t.TempDir())
+	port := freePort(t)
+	add := func(name, cipher, password, port string) R.CallReply {
+		return call(t, c, "UserRpc.AddUser", &R.NewUserArgs{Name: name, Cipher: cipher, Password: password, Port: port})
+	}
+
+	expectCode(t, "add", add("a", "AES-256-GCM", "a", port), NO_ERROR)
+	expectCode(t, "add again", add("a", "AES-256-GCM", "b", freePort(t)), USER_EXISTS)
+	expectCode(t, "stop unknown", call(t, c, "UserRpc.StopUser", &R.CommonArgs{Name: "x"}), USER_NON_EXISTS)
+	expectCode(t, "start unknown", call(t, c, "UserRpc.StartUser", &R.CommonArgs{Name: "x"}), USER_NON_EXISTS)
+	expectCode(t, "bad cipher", add("b", "RC4-MD5", "b", freePort(t)), INVALID_CIPHER)
+	expectCode(t, "same key", add("b", "AES-256-GCM", "a", port), PORT_IN_USE)
+	expectCode(t, "other key", add("b", "AES-256-GCM", "b", port), NO_ERROR)
+	expectCode(t, "bad port", add("c", "AES-256-GCM", "c", "99999"), LISTEN_ERROR)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	busy := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	expectCode(t, "busy port", add("c", "AES-256-GCM", "c", busy), PORT_IN_USE)
+	expectCode(t, "modify cipher", call(t, c, "UserRpc.Modify", &R.CommonArgs{Name: "a", Cipher: "CHACHA20-IETF-POLY1305"}), NO_ERROR)
+	expectCode(t, "modify to a key in use", call(t, c, "UserRpc.Modify", &R.CommonArgs{Name: "a", Cipher: "AES-256-GCM", Password: "b"}), PORT_IN_USE)
+	expectCode(t, "modify unknown", call(t, c, "UserRpc.Modify", &R.CommonArgs{Name: "x", Password: "x"}), USER_NON_EXISTS)
+	expectCode(t, "delete", call(t, c, "UserRpc.DeleteUser", &R.CommonArgs{Name: "b"}), NO_ERROR)
+	expectCode(t, "delete again", call(t, c, "UserRpc.DeleteUser", &R.CommonArgs{Name: "b"}), USER_NON_EXISTS)
+}
+
+func TestErrCodeAuth(t *testing.T) {
+	r, c := testRpc(t, t.TempDir())
+	r.SetAuthKeys(map[string]string{"id": "secret"}, time.Minute)
+
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	expectCode(t, "unsigned", call(t, c, "UserRpc.AddUser", args), AUTH_ERROR)
+	if err := R.Sign("UserRpc.AddUser", args, "id", "wrong"); err != nil {
+		t.Fatal(err)
+	}
+	expectCode(t, "bad secret", call(t, c, "UserRpc.AddUser", args), AUTH_ERROR)
+	if err := R.Sign("UserRpc.AddUser", args, "id", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	expectCode(t, "signed", call(t, c, "UserRpc.AddUser", args), NO_ERROR)
+	expectCode(t, "replayed", call(t, c, "UserRpc.AddUser", args), AUTH_ERROR)
+}
+
+func TestErrCodeStore(t *testing.T) {
+	dir := t.TempDir()
+	_, c := testRpc(t, dir)
+	// the file store can't save once its directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	expectCode(t, "add", call(t, c, "UserRpc.AddUser", args), REDIS_ERROR)
+	expectCode(t, "add after failure", call(t, c, "UserRpc.AddUser", args), REDIS_ERROR)
+}
+
+func TestModifyStopped(t *testing.T) {
+	r, c := testRpc(t, t.TempDir())
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	expectCode(t, "add", call(t, c, "UserRpc.AddUser", args), NO_ERROR)
+	expectCode(t, "stop", call(t, c, "UserRpc.StopUser", &R.CommonArgs{Name: "a"}), NO_ERROR)
+
+	modify := func(cipher, password string) R.CallReply {
+		return call(t, c, "UserRpc.Modify", &R.CommonArgs{Name: "a", Cipher: cipher, Password: password})
+	}
+	expectCode(t, "bad cipher", modify("bogus", ""), INVALID_CIPHER)
+	expectCode(t, "bad psk", modify("2022-BLAKE3-AES-128-GCM", "not base64"), INVALID_PASSWORD)
+	expectCode(t, "short psk", modify("2022-BLAKE3-AES-128-GCM", "AAAA"), INVALID_PASSWORD)
+	expectCode(t, "start", call(t, c, "UserRpc.StartUser", &R.CommonArgs{Name: "a"}), NO_ERROR)
+	expectCode(t, "stop again", call(t, c, "UserRpc.StopUser", &R.CommonArgs{Name: "a"}), NO_ERROR)
+
+	// a record which can't start is kept
+	su, err := r.st.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	su.Cipher = "bogus"
+	if err := r.st.Put("a", su.Record); err != nil {
+		t.Fatal(err)
+	}
+	expectCode(t, "start bad record", call(t, c, "UserRpc.StartUser", &R.CommonArgs{Name: "a"}), INVALID_CIPHER)
+	if _, err := r.st.Get("a"); err != nil {
+		t.Fatalf("record dropped: %v", err)
+	}
+	expectCode(t, "fix", modify("AES-256-GCM", ""), NO_ERROR)
+	expectCode(t, "start fixed", call(t, c, "UserRpc.StartUser", &R.CommonArgs{Name: "a"}), NO_ERROR)
+}
+
+func TestStopWhileExpiring(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	for i := 0; i < 20; i++ {
+		name := "u" + strconv.Itoa(i)
+		args := &R.NewUserArgs{Name: name, Cipher: "AES-256-GCM", Password: name, Port: freePort(t)}
+		if err := r.addUser(args); err != nil {
+			t.Fatal(err)
+		}
+		// expired in the store, as if the timer fired
+		err := r.updateRecord(name, func(rec *store.Record) {
+			rec.ExpireAt = time.Now().Unix() - 1
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- r.stopUser(&R.CommonArgs{Name: name})
+		}()
+		r.expire(name)
+		if err := <-done; err != nil && err != errUserNonExists {
+			t.Fatal(err)
+		}
+		if r.Users.Exists(name) {
+			t.Fatalf("%s still running", name)
+		}
+		su, err := r.st.Get(name)
+		if err != nil || !su.Expired {
+			t.Fatalf("%s not marked expired: %+v %v", name, su, err)
+		}
+	}
+}
+
+func TestStopWhileResetting(t *testing.T) {
+	r, _ := testRpc(t, t.TempDir())
+	for i := 0; i < 20; i++ {
+		name := "u" + strconv.Itoa(i)
+		args := &R.NewUserArgs{Name: name, Cipher: "AES-256-GCM", Password: name, Port: freePort(t)}
+		if err := r.addUser(args); err != nil {
+			t.Fatal(err)
+		}
+		r.Users.SetUser(name, 100, 1)
+		// due in the store, as if the timer fired
+		err := r.updateRecord(name, func(rec *store.Record) {
+			rec.ResetDays, rec.ResetAnchor = 1, time.Now().Unix()-1
+			rec.NextReset = rec.ResetAnchor
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- r.stopUser(&R.CommonArgs{Name: name})
+		}()
+		r.resetScheduled(name)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if r.Users.Exists(name) {
+			t.Fatalf("%s still running", name)
+		}
+		hs, err := r.st.History(name)
+		if err != nil || len(hs) != 1 || hs[0].Traffic != 100 {
+			t.Fatalf("%s history: %+v %v", name, hs, err)
+		}
+		su, err := r.st.Get(name)
+		if err != nil || su.Traffic != 0 {
+			t.Fatalf("%s counters after the reset: %+v %v", name, su, err)
+		}
+	}
+}
+
+func TestResetScheduleResent(t *testing.T) {
+	r, c := testRpc(t, t.TempDir())
+	args := &R.NewUserArgs{Name: "a", Cipher: "AES-256-GCM", Password: "a", Port: freePort(t)}
+	expectCode(t, "add", call(t, c, "UserRpc.AddUser", args), NO_ERROR)
+	schedule := func(day, days int) int64 {
+		t.Helper()
+		expectCode(t, "schedule", call(t, c, "UserRpc.SetResetSchedule", &R.ResetScheduleArgs{Name: "a", Day: day, Days: days}), NO_ERROR)
+		su, err := r.st.Get("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return su.NextReset
+	}
+	first := schedule(0, 30)
+	time.Sleep(1100 * time.Millisecond)
+	if next := schedule(0, 30); next != first {
+		t.Fatalf("resent schedule moved the reset from %d to %d", first, next)
+	}
+	if next := schedule(0, 7); next == first {
+		t.Fatal("new period kept the reset")
+	}
+	if next := schedule(0, 0); next != 0 {
+		t.Fatalf("cleared schedule resets at %d", next)
+	}
+}
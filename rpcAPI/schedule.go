@@ -0,0 +1,42 @@
+package rpcapi
+
+import (
+	"sync"
+	"time"
+)
+
+// userTimers runs a function per user at a given time.
+type userTimers struct {
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// schedule calls f with the user at at in Unix seconds, replacing the one
+// scheduled before. 0 cancels it.
+func (e *userTimers) schedule(name string, at int64, f func(string)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if t, ok := e.timers[name]; ok {
+		t.Stop()
+		delete(e.timers, name)
+	}
+	if at <= 0 {
+		return
+	}
+	if e.timers == nil {
+		e.timers = make(map[string]*time.Timer)
+	}
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(time.Unix(at, 0)), func() {
+		e.lock.Lock()
+		// rescheduled meanwhile
+		if e.timers[name] != t {
+			e.lock.Unlock()
+			return
+		}
+		delete(e.timers, name)
+		e.lock.Unlock()
+		f(name)
+	})
+	e.timers[name] = t
+}
@@ -0,0 +1,68 @@
+package rpcapi
+
+import (
+	"errors"
+	"time"
+
+	R "github.com/BishiNET/ss-server/rpcinterface"
+	"github.com/BishiNET/ss-server/store"
+)
+
+var errBadRange = errors.New("invalid range")
+
+// GetUsage has no error code in its reply, failures are returned as errors.
+func (r *UserRpc) GetUsage(args *R.UsageArgs, reply *R.UsageReply) error {
+	u, err := r.getUsage(args)
+	if err != nil {
+		return err
+	}
+	*reply = u
+	return nil
+}
+
+func (r *UserRpc) getUsage(args *R.UsageArgs) (R.UsageReply, error) {
+	if err := r.authorize("UserRpc.GetUsage", args); err != nil {
+		return nil, err
+	}
+	var period, span time.Duration
+	switch args.Period {
+	case "hour", "":
+		period, span = store.Hour, store.Day
+	case "day":
+		period, span = store.Day, 30*store.Day
+	default:
+		return nil, store.ErrBadPeriod
+	}
+	now := time.Now().Unix()
+	from, to := args.From, args.To
+	if to == 0 {
+		to = now
+	}
+	// no bucket is later than the current one
+	if latest := now + int64(period/time.Second); to > latest {
+		to = latest
+	}
+	if from == 0 {
+		from = to - int64(span/time.Second)
+	}
+	// nor wider than the buckets kept
+	if from > to || to-from > int64((store.Retention(period)+period)/time.Second) {
+		return nil, errBadRange
+	}
+	bs, err := r.st.Usage(args.Name, period, from, to)
+	if err != nil {
+		if errors.Is(err, store.ErrBadPeriod) {
+			return nil, err
+		}
+		return nil, storeErr(err)
+	}
+	reply := make(R.UsageReply, len(bs))
+	for i, b := range bs {
+		c := fromStore(b.Counters)
+		reply[i] = R.UsageBucket{
+			Start:              b.Start,
+			SingleTrafficReply: trafficReply(c.traffic, c.time, c.detail),
+		}
+	}
+	return reply, nil
+}
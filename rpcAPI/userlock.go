@@ -0,0 +1,42 @@
+package rpcapi
+
+import "sync"
+
+// userLocks serialises the starts and stops of each user, so that the RPC
+// calls, the cluster events and the timers find a user either running or
+// stopped, never half way.
+type userLocks struct {
+	lock  sync.Mutex
+	users map[string]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	// refs counts the holders and waiters, the lock is dropped at 0
+	refs int
+}
+
+// hold locks the user, the returned func unlocks it.
+func (l *userLocks) hold(name string) func() {
+	l.lock.Lock()
+	if l.users == nil {
+		l.users = make(map[string]*userLock)
+	}
+	ul, ok := l.users[name]
+	if !ok {
+		ul = &userLock{}
+		l.users[name] = ul
+	}
+	ul.refs++
+	l.lock.Unlock()
+
+	ul.Lock()
+	return func() {
+		ul.Unlock()
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if ul.refs--; ul.refs == 0 {
+			delete(l.users, name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rpcinterface
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrBadSign = errors.New("bad signature")
+
+// Signed is implemented by every args struct embedding Auth.
+type Signed interface {
+	Credentials() *Auth
+}
+
+func (a *Auth) Credentials() *Auth { return a }
+
+// payload is what the signature covers: the method, the credentials but the
+// signature itself and the args.
+func payload(method string, args Signed) ([]byte, error) {
+	a := args.Credentials()
+	saved := *a
+	*a = Auth{}
+	b, err := json.Marshal(args)
+	*a = saved
+	if err != nil {
+		return nil, err
+	}
+	p := []byte(method + "\n" + a.AccessID + "\n" + a.AccessToken + "\n" + strconv.FormatInt(a.Timestamp, 10) + "\n")
+	return append(p, b...), nil
+}
+
+func mac(secret string, p []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(p)
+	return h.Sum(nil)
+}
+
+// Sign signs a call of method, e.g. "UserRpc.AddUser", with the key id.
+func Sign(method string, args Signed, id, secret string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	a := args.Credentials()
+	a.AccessID = id
+	a.AccessToken = hex.EncodeToString(nonce)
+	a.Timestamp = time.Now().Unix()
+	p, err := payload(method, args)
+	if err != nil {
+		return err
+	}
+	a.Sign = hex.EncodeToString(mac(secret, p))
+	return nil
+}
+
+// Verify checks the signature of a call of method with secret.
+func Verify(method string, args Signed, secret string) error {
+	sign, err := hex.DecodeString(args.Credentials().Sign)
+	if err != nil {
+		return ErrBadSign
+	}
+	p, err := payload(method, args)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sign, mac(secret, p)) {
+		return ErrBadSign
+	}
+	return nil
+}
@@ -0,0 +1,509 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: userrpc.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	UserRpc_AddUser_FullMethodName       = "/ssserver.UserRpc/AddUser"
+	UserRpc_StartUser_FullMethodName     = "/ssserver.UserRpc/StartUser"
+	UserRpc_StopUser_FullMethodName      = "/ssserver.UserRpc/StopUser"
+	UserRpc_DeleteUser_FullMethodName    = "/ssserver.UserRpc/DeleteUser"
+	UserRpc_Modify_FullMethodName        = "/ssserver.UserRpc/Modify"
+	UserRpc_GetUser_FullMethodName       = "/ssserver.UserRpc/GetUser"
+	UserRpc_ResetAll_FullMethodName      = "/ssserver.UserRpc/ResetAll"
+	UserRpc_UpgradeFilter_FullMethodName = "/ssserver.UserRpc/UpgradeFilter"
+	UserRpc_AddFilter_FullMethodName     = "/ssserver.UserRpc/AddFilter"
+	UserRpc_FilterStatus_FullMethodName  = "/ssserver.UserRpc/FilterStatus"
+	UserRpc_WatchTraffic_FullMethodName  = "/ssserver.UserRpc/WatchTraffic"
+)
+
+// UserRpcClient is the client API for UserRpc service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UserRpcClient interface {
+	AddUser(ctx context.Context, in *NewUserArgs, opts ...grpc.CallOption) (*CallReply, error)
+	StartUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error)
+	StopUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error)
+	DeleteUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error)
+	Modify(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error)
+	GetUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*TrafficReply, error)
+	ResetAll(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*CallReply, error)
+	UpgradeFilter(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*CallReply, error)
+	AddFilter(ctx context.Context, in *Filters, opts ...grpc.CallOption) (*FilterReply, error)
+	FilterStatus(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*FilterStatusReply, error)
+	// WatchTraffic pushes the counters of the users changed every interval.
+	WatchTraffic(ctx context.Context, in *WatchArgs, opts ...grpc.CallOption) (UserRpc_WatchTrafficClient, error)
+}
+
+type userRpcClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserRpcClient(cc grpc.ClientConnInterface) UserRpcClient {
+	return &userRpcClient{cc}
+}
+
+func (c *userRpcClient) AddUser(ctx context.Context, in *NewUserArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_AddUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) StartUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_StartUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) StopUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_StopUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) DeleteUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_DeleteUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) Modify(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_Modify_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) GetUser(ctx context.Context, in *CommonArgs, opts ...grpc.CallOption) (*TrafficReply, error) {
+	out := new(TrafficReply)
+	err := c.cc.Invoke(ctx, UserRpc_GetUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) ResetAll(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_ResetAll_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) UpgradeFilter(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*CallReply, error) {
+	out := new(CallReply)
+	err := c.cc.Invoke(ctx, UserRpc_UpgradeFilter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) AddFilter(ctx context.Context, in *Filters, opts ...grpc.CallOption) (*FilterReply, error) {
+	out := new(FilterReply)
+	err := c.cc.Invoke(ctx, UserRpc_AddFilter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) FilterStatus(ctx context.Context, in *NoArgs, opts ...grpc.CallOption) (*FilterStatusReply, error) {
+	out := new(FilterStatusReply)
+	err := c.cc.Invoke(ctx, UserRpc_FilterStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userRpcClient) WatchTraffic(ctx context.Context, in *WatchArgs, opts ...grpc.CallOption) (UserRpc_WatchTrafficClient, error) {
+	stream, err := c.cc.NewStream(ctx, &UserRpc_ServiceDesc.Streams[0], UserRpc_WatchTraffic_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &userRpcWatchTrafficClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type UserRpc_WatchTrafficClient interface {
+	Recv() (*TrafficDelta, error)
+	grpc.ClientStream
+}
+
+type userRpcWatchTrafficClient struct {
+	grpc.ClientStream
+}
+
+func (x *userRpcWatchTrafficClient) Recv() (*TrafficDelta, error) {
+	m := new(TrafficDelta)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UserRpcServer is the server API for UserRpc service.
+// All implementations must embed UnimplementedUserRpcServer
+// for forward compatibility
+type UserRpcServer interface {
+	AddUser(context.Context, *NewUserArgs) (*CallReply, error)
+	StartUser(context.Context, *CommonArgs) (*CallReply, error)
+	StopUser(context.Context, *CommonArgs) (*CallReply, error)
+	DeleteUser(context.Context, *CommonArgs) (*CallReply, error)
+	Modify(context.Context, *CommonArgs) (*CallReply, error)
+	GetUser(context.Context, *CommonArgs) (*TrafficReply, error)
+	ResetAll(context.Context, *NoArgs) (*CallReply, error)
+	UpgradeFilter(context.Context, *NoArgs) (*CallReply, error)
+	AddFilter(context.Context, *Filters) (*FilterReply, error)
+	FilterStatus(context.Context, *NoArgs) (*FilterStatusReply, error)
+	// WatchTraffic pushes the counters of the users changed every interval.
+	WatchTraffic(*WatchArgs, UserRpc_WatchTrafficServer) error
+	mustEmbedUnimplementedUserRpcServer()
+}
+
+// UnimplementedUserRpcServer must be embedded to have forward compatible implementations.
+type UnimplementedUserRpcServer struct {
+}
+
+func (UnimplementedUserRpcServer) AddUser(context.Context, *NewUserArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddUser not implemented")
+}
+func (UnimplementedUserRpcServer) StartUser(context.Context, *CommonArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartUser not implemented")
+}
+func (UnimplementedUserRpcServer) StopUser(context.Context, *CommonArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopUser not implemented")
+}
+func (UnimplementedUserRpcServer) DeleteUser(context.Context, *CommonArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserRpcServer) Modify(context.Context, *CommonArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Modify not implemented")
+}
+func (UnimplementedUserRpcServer) GetUser(context.Context, *CommonArgs) (*TrafficReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedUserRpcServer) ResetAll(context.Context, *NoArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetAll not implemented")
+}
+func (UnimplementedUserRpcServer) UpgradeFilter(context.Context, *NoArgs) (*CallReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpgradeFilter not implemented")
+}
+func (UnimplementedUserRpcServer) AddFilter(context.Context, *Filters) (*FilterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddFilter not implemented")
+}
+func (UnimplementedUserRpcServer) FilterStatus(context.Context, *NoArgs) (*FilterStatusReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FilterStatus not implemented")
+}
+func (UnimplementedUserRpcServer) WatchTraffic(*WatchArgs, UserRpc_WatchTrafficServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTraffic not implemented")
+}
+func (UnimplementedUserRpcServer) mustEmbedUnimplementedUserRpcServer() {}
+
+// UnsafeUserRpcServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserRpcServer will
+// result in compilation errors.
+type UnsafeUserRpcServer interface {
+	mustEmbedUnimplementedUserRpcServer()
+}
+
+func RegisterUserRpcServer(s grpc.ServiceRegistrar, srv UserRpcServer) {
+	s.RegisterService(&UserRpc_ServiceDesc, srv)
+}
+
+func _UserRpc_AddUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewUserArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).AddUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_AddUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).AddUser(ctx, req.(*NewUserArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_StartUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommonArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).StartUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_StartUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).StartUser(ctx, req.(*CommonArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_StopUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommonArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).StopUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_StopUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).StopUser(ctx, req.(*CommonArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommonArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).DeleteUser(ctx, req.(*CommonArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_Modify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommonArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).Modify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_Modify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).Modify(ctx, req.(*CommonArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommonArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).GetUser(ctx, req.(*CommonArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_ResetAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).ResetAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_ResetAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).ResetAll(ctx, req.(*NoArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_UpgradeFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).UpgradeFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_UpgradeFilter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).UpgradeFilter(ctx, req.(*NoArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_AddFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Filters)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).AddFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_AddFilter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).AddFilter(ctx, req.(*Filters))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_FilterStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NoArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserRpcServer).FilterStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserRpc_FilterStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserRpcServer).FilterStatus(ctx, req.(*NoArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserRpc_WatchTraffic_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchArgs)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserRpcServer).WatchTraffic(m, &userRpcWatchTrafficServer{stream})
+}
+
+type UserRpc_WatchTrafficServer interface {
+	Send(*TrafficDelta) error
+	grpc.ServerStream
+}
+
+type userRpcWatchTrafficServer struct {
+	grpc.ServerStream
+}
+
+func (x *userRpcWatchTrafficServer) Send(m *TrafficDelta) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// UserRpc_ServiceDesc is the grpc.ServiceDesc for UserRpc service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserRpc_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ssserver.UserRpc",
+	HandlerType: (*UserRpcServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddUser",
+			Handler:    _UserRpc_AddUser_Handler,
+		},
+		{
+			MethodName: "StartUser",
+			Handler:    _UserRpc_StartUser_Handler,
+		},
+		{
+			MethodName: "StopUser",
+			Handler:    _UserRpc_StopUser_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _UserRpc_DeleteUser_Handler,
+		},
+		{
+			MethodName: "Modify",
+			Handler:    _UserRpc_Modify_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _UserRpc_GetUser_Handler,
+		},
+		{
+			MethodName: "ResetAll",
+			Handler:    _UserRpc_ResetAll_Handler,
+		},
+		{
+			MethodName: "UpgradeFilter",
+			Handler:    _UserRpc_UpgradeFilter_Handler,
+		},
+		{
+			MethodName: "AddFilter",
+			Handler:    _UserRpc_AddFilter_Handler,
+		},
+		{
+			MethodName: "FilterStatus",
+			Handler:    _UserRpc_FilterStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTraffic",
+			Handler:       _UserRpc_WatchTraffic_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "userrpc.proto",
+}
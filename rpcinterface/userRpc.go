@@ -1,38 +1,186 @@
-package rpcinterface
-
-type CallReply struct {
-	ErrCode   int
-	ErrReason string
-}
-
-type Auth struct {
-	AccessID    string
-	AccessToken string
-	Sign        string
-}
-type NewUserArgs struct {
-	Name     string
-	Cipher   string
-	Password string
-	Port     string
-}
-
-type CommonArgs struct {
-	Name     string
-	Password string
-	Cipher   string
-}
-
-type NoArgs struct {
-}
-
-type Filters struct {
-	URL []string
-}
-
-type SingleTrafficReply struct {
-	Traffic  uint64
-	UsedTime int64
-}
-
-type TrafficReply map[string]SingleTrafficReply
+package rpcinterface
+
+// CallReply is filled on success and failure alike, a failed call is
+// reported by a non-zero ErrCode rather than the error of the call.
+type CallReply struct {
+	ErrCode   int
+	ErrReason string
+}
+
+// ReloadReply lists the settings changed by the reload.
+type ReloadReply struct {
+	ErrCode         int
+	ErrReason       string
+	Applied         []string
+	RestartRequired []string
+}
+
+// Auth signs a call, see Sign. It is embedded in every args.
+type Auth struct {
+	AccessID string
+	// AccessToken is a random nonce of the call.
+	AccessToken string
+	// Timestamp is in Unix seconds.
+	Timestamp int64
+	Sign      string
+}
+type NewUserArgs struct {
+	Auth
+	Name   string
+	Cipher string
+	// Password of 2022 Edition ciphers is the base64 encoded PSK, or
+	// "iPSK:uPSK" to identify the user by identity headers on a shared port.
+	Password string
+	// Users naming the same port share one listener.
+	Port string
+	// Quota is the traffic limit in bytes, 0 means unlimited.
+	Quota uint64
+	// Bandwidth limits in bytes per second, 0 means unlimited.
+	UpLimit   int64
+	DownLimit int64
+	// ExpireAt stops the user at the time in Unix seconds, 0 never.
+	ExpireAt int64
+}
+
+type CommonArgs struct {
+	Auth
+	Name     string
+	Password string
+	Cipher   string
+	// ExpireAt changes the expiry on Modify, 0 keeps it and negative
+	// clears it.
+	ExpireAt int64
+}
+
+type QuotaArgs struct {
+	Auth
+	Name string
+	// Quota is the traffic limit in bytes, 0 clears the limit.
+	Quota uint64
+}
+
+type RateLimitArgs struct {
+	Auth
+	Name string
+	// Bandwidth limits in bytes per second, 0 means unlimited.
+	UpLimit   int64
+	DownLimit int64
+}
+
+// ResetScheduleArgs resets the counters of a user monthly on Day, or
+// every Days days from now. Both 0 clears the schedule, the same schedule
+// sent again keeps the next reset.
+type ResetScheduleArgs struct {
+	Auth
+	Name string
+	Day  int
+	Days int
+}
+
+// UsageArgs queries the usage buckets of a user, Period is "hour" or
+// "day". From and To are in Unix seconds, To defaults to now and From to
+// a day or a month before.
+type UsageArgs struct {
+	Auth
+	Name   string
+	Period string
+	From   int64
+	To     int64
+}
+
+// WatchArgs subscribes to the traffic deltas over gRPC.
+type WatchArgs struct {
+	Auth
+	// Name limits the deltas to one user.
+	Name       string
+	IntervalMs int64
+}
+
+type NoArgs struct {
+	Auth
+}
+
+type Filters struct {
+	Auth
+	// URL may declare the format of the list in its fragment, "#plain",
+	// "#hosts", "#adblock" or "#dnsmasq", it is detected per line without.
+	URL []string
+}
+
+// FilterReply counts the lines of each blocklist added.
+type FilterReply struct {
+	ErrCode   int
+	ErrReason string
+	Sources   []FilterSource
+}
+
+// FilterSource is set Error when the list couldn't be fetched, the lines
+// read until then are counted.
+type FilterSource struct {
+	URL      string
+	Format   string
+	Accepted int
+	Rejected int
+	// Cached tells the remote list was unreachable and read from the cache
+	Cached bool
+	Error  string
+	// Checked and Updated are set by FilterStatus, when the list was last
+	// checked and when the remote list last changed, in Unix seconds.
+	Checked int64
+	Updated int64
+}
+
+// FilterStatusReply is the domain filter in use and the lists as last
+// checked. Times are in Unix seconds, 0 never.
+type FilterStatusReply struct {
+	ErrCode   int
+	ErrReason string
+	Backend   string
+	Domains   int
+	// Memory is an estimate in bytes
+	Memory  int64
+	Built   int64
+	Checked int64
+	// Error is the first list failed in the last check, keeping the
+	// filter in use
+	Error string
+	// RefreshInterval is in seconds, 0 disables the refresh
+	RefreshInterval int64
+	Sources         []FilterSource
+}
+
+type SingleTrafficReply struct {
+	// Traffic is the total of both directions and protocols.
+	Traffic  uint64
+	UsedTime int64
+
+	// Upload is from the client to the remote, download is the other way round.
+	Upload      uint64
+	Download    uint64
+	TCPUpload   uint64
+	TCPDownload uint64
+	UDPUpload   uint64
+	UDPDownload uint64
+}
+
+type TrafficReply map[string]SingleTrafficReply
+
+// HistoryEntry is the traffic of a user when it was reset at At, in Unix
+// seconds.
+type HistoryEntry struct {
+	At int64
+	SingleTrafficReply
+}
+
+// HistoryReply lists the latest resets first.
+type HistoryReply []HistoryEntry
+
+// UsageBucket is the traffic of a user over the hour or day from Start,
+// in Unix seconds.
+type UsageBucket struct {
+	Start int64
+	SingleTrafficReply
+}
+
+// UsageReply lists the buckets oldest first, leaving out the empty ones.
+type UsageReply []UsageBucket
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/kpango/fastime"
+	"golang.org/x/crypto/chacha20poly1305"
+	"lukechampine.com/blake3"
+)
+
+// Shadowsocks 2022 Edition, see
+// https://github.com/Shadowsocks-NET/shadowsocks-specs/blob/main/2022-1-shadowsocks-2022-edition.md
+const (
+	blake3Aes128Gcm        = "2022-BLAKE3-AES-128-GCM"
+	blake3Aes256Gcm        = "2022-BLAKE3-AES-256-GCM"
+	blake3Chacha20Poly1305 = "2022-BLAKE3-CHACHA20-POLY1305"
+)
+
+const (
+	headerTypeClientStream = 0
+	headerTypeServerStream = 1
+	headerTypeClientPacket = 0
+	headerTypeServerPacket = 1
+
+	// payloadSizeMask2022 is the maximum size of payload in bytes of 2022 Edition.
+	payloadSizeMask2022 = 0xFFFF
+	maxPaddingLength    = 900
+	maxTimeDiff         = 30 // in seconds
+)
+
+var (
+	// ErrBadHeaderType means the header type doesn't match the direction.
+	ErrBadHeaderType = errors.New("bad header type")
+	// ErrBadTimestamp means the header timestamp is too far away from now.
+	ErrBadTimestamp = errors.New("bad timestamp")
+	// ErrPaddingExceeded means the padding length is larger than allowed.
+	ErrPaddingExceeded = errors.New("padding length exceeded")
+	// ErrPacketReplayed means the packet ID has been seen in the session.
+	ErrPacketReplayed = errors.New("replayed packet")
+	// ErrIdentityNotSupported means identity headers are used with a cipher other than AES.
+	ErrIdentityNotSupported = errors.New("identity headers require AES")
+)
+
+// List of 2022 Edition ciphers: key size in bytes and constructor
+var blake3List = map[string]struct {
+	KeySize int
+	New     func(psk, ipsk []byte, u *User) (ConnCipher, error)
+}{
+	blake3Aes128Gcm:        {16, AES2022},
+	blake3Aes256Gcm:        {32, AES2022},
+	blake3Chacha20Poly1305: {32, Chacha2022},
+}
+
+// decodePSK decodes the base64 encoded pre-shared keys of 2022 Edition.
+// The password is either "uPSK" or "iPSK:uPSK" for users identified by
+// identity headers on a shared port.
+func decodePSK(password string, keySize int) (ipsk, psk []byte, err error) {
+	keys := strings.Split(password, ":")
+	if len(keys) > 2 {
+		return nil, nil, ErrCipherNotSupported
+	}
+	for i, k := range keys {
+		b, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(b) != keySize {
+			return nil, nil, KeySizeError(keySize)
+		}
+		if i == len(keys)-1 {
+			psk = b
+		} else {
+			ipsk = b
+		}
+	}
+	return ipsk, psk, nil
+}
+
+func blake3SessionKey(psk, salt []byte) []byte {
+	subkey := make([]byte, len(psk))
+	blake3.DeriveKey(subkey, "shadowsocks 2022 session subkey", append(append([]byte{}, psk...), salt...))
+	return subkey
+}
+
+func blake3IdentityKey(ipsk, salt []byte) []byte {
+	subkey := make([]byte, len(ipsk))
+	blake3.DeriveKey(subkey, "shadowsocks 2022 identity subkey", append(append([]byte{}, ipsk...), salt...))
+	return subkey
+}
+
+// identityHash is what an identity header carries for psk.
+func identityHash(psk []byte) (id [aes.BlockSize]byte) {
+	h := blake3.Sum256(psk)
+	copy(id[:], h[:])
+	return
+}
+
+// blake3Cipher derives session subkeys with BLAKE3 instead of HKDF-SHA1.
+type blake3Cipher struct {
+	psk      []byte
+	makeAEAD func(key []byte) (cipher.AEAD, error)
+}
+
+func (a *blake3Cipher) KeySize() int  { return len(a.psk) }
+func (a *blake3Cipher) SaltSize() int { return len(a.psk) }
+func (a *blake3Cipher) Encrypter(salt []byte) (cipher.AEAD, error) {
+	return a.makeAEAD(blake3SessionKey(a.psk, salt))
+}
+func (a *blake3Cipher) Decrypter(salt []byte) (cipher.AEAD, error) {
+	return a.makeAEAD(blake3SessionKey(a.psk, salt))
+}
+
+type cipher2022 struct {
+	*blake3Cipher
+	*User
+	// block encrypts the separate header of AES packets.
+	block cipher.Block
+	// xaead seals whole ChaCha20 packets.
+	xaead cipher.AEAD
+	// ipsk is the identity PSK shared by the users of a port, and iblock
+	// decrypts the separate header of incoming packets with it.
+	ipsk   []byte
+	iblock cipher.Block
+}
+
+// AES2022 creates a 2022 Edition cipher with AES-GCM. len(psk) must be 16 or 32.
+// ipsk is optional and enables identity headers.
+func AES2022(psk, ipsk []byte, u *User) (ConnCipher, error) {
+	block, err := aes.NewCipher(psk)
+	if err != nil {
+		return nil, err
+	}
+	c := &cipher2022{
+		blake3Cipher: &blake3Cipher{psk: psk, makeAEAD: aesGCM},
+		User:         u,
+		block:        block,
+	}
+	if ipsk != nil {
+		if c.iblock, err = aes.NewCipher(ipsk); err != nil {
+			return nil, err
+		}
+		c.ipsk = ipsk
+	}
+	return c, nil
+}
+
+// Chacha2022 creates a 2022 Edition cipher with ChaCha20-Poly1305. len(psk)
+// must be 32.
+func Chacha2022(psk, ipsk []byte, u *User) (ConnCipher, error) {
+	if ipsk != nil {
+		return nil, ErrIdentityNotSupported
+	}
+	xaead, err := chacha20poly1305.NewX(psk)
+	if err != nil {
+		return nil, err
+	}
+	return &cipher2022{
+		blake3Cipher: &blake3Cipher{psk: psk, makeAEAD: chacha20poly1305.New},
+		User:         u,
+		xaead:        xaead,
+	}, nil
+}
+
+func (c *cipher2022) streamProbeSize() int { return c.SaltSize() + 1 + 8 + 2 + 16 }
+
+// probeStream opens the fixed-length header of a request.
+func (c *cipher2022) probeStream(b []byte) bool {
+	aead, err := c.Decrypter(b[:c.SaltSize()])
+	if err != nil {
+		return false
+	}
+	header := append([]byte{}, b[c.SaltSize():]...)
+	header, err = aead.Open(header[:0], _zerononce[:aead.NonceSize()], header, nil)
+	return err == nil && header[0] == headerTypeClientStream
+}
+
+func (c *cipher2022) StreamConn(conn net.Conn) net.Conn {
+	return &streamConn2022{Conn: conn, cipher2022: c}
+}
+
+func (c *cipher2022) PacketConn(conn net.PacketConn) net.PacketConn {
+	return newPacketConn2022(conn, c)
+}
+
+// validTimestamp reports whether ts is within maxTimeDiff from now.
+func validTimestamp(ts uint64) bool {
+	diff := fastime.UnixNanoNow()/int64(time.Second) - int64(ts)
+	return diff <= maxTimeDiff && diff >= -maxTimeDiff
+}
+
+func nowTimestamp() uint64 {
+	return uint64(fastime.UnixNanoNow() / int64(time.Second))
+}
@@ -1,35 +1,48 @@
-package server
-
-import (
-	"fmt"
-	"log"
-	"os"
-)
-
-var logger = log.New(os.Stderr, "", log.Lshortfile|log.LstdFlags)
-
-const (
-	isLogger = true
-)
-
-func logf(f string, v ...interface{}) {
-	if isLogger {
-		logger.Output(2, fmt.Sprintf(f, v...))
-	}
-}
-
-type logHelper struct {
-	prefix string
-}
-
-func (l *logHelper) Write(p []byte) (n int, err error) {
-	if isLogger {
-		logger.Printf("%s%s\n", l.prefix, p)
-		return len(p), nil
-	}
-	return len(p), nil
-}
-
-func newLogHelper(prefix string) *logHelper {
-	return &logHelper{prefix}
-}
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+var logger = log.New(os.Stderr, "", log.Lshortfile|log.LstdFlags)
+
+// quiet is set to 1 to silence the logs
+var quiet int32
+
+// SetQuiet turns the server logs off or back on.
+func SetQuiet(q bool) {
+	var v int32
+	if q {
+		v = 1
+	}
+	atomic.StoreInt32(&quiet, v)
+}
+
+func isLogger() bool {
+	return atomic.LoadInt32(&quiet) == 0
+}
+
+func logf(f string, v ...interface{}) {
+	if isLogger() {
+		logger.Output(2, fmt.Sprintf(f, v...))
+	}
+}
+
+type logHelper struct {
+	prefix string
+}
+
+func (l *logHelper) Write(p []byte) (n int, err error) {
+	if isLogger() {
+		logger.Printf("%s%s\n", l.prefix, p)
+		return len(p), nil
+	}
+	return len(p), nil
+}
+
+func newLogHelper(prefix string) *logHelper {
+	return &logHelper{prefix}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/fastime"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const udpSessionTimeout = 5 * time.Minute
+
+var errNoSession = errors.New("no session for the peer")
+
+// slidingWindow rejects replayed or too old packet IDs of a session.
+type slidingWindow struct {
+	last   uint64
+	bitmap uint64
+}
+
+const windowSize = 64
+
+func (w *slidingWindow) check(id uint64) bool {
+	if id > w.last {
+		if diff := id - w.last; diff < windowSize {
+			w.bitmap <<= diff
+		} else {
+			w.bitmap = 0
+		}
+		w.bitmap |= 1
+		w.last = id
+		return true
+	}
+	diff := w.last - id
+	if diff >= windowSize || w.bitmap&(1<<diff) != 0 {
+		return false
+	}
+	w.bitmap |= 1 << diff
+	return true
+}
+
+type udpSession struct {
+	clientID []byte
+	serverID []byte
+	packetID uint64 // next packet ID sent to the client
+	filter   slidingWindow
+	recv     cipher.AEAD // AES only
+	send     cipher.AEAD // AES only
+	lastSeen int64
+}
+
+type packetConn2022 struct {
+	net.PacketConn
+	*cipher2022
+	sync.Mutex
+	buf []byte // write lock
+
+	sessionLock sync.Mutex
+	sessions    map[string]*udpSession
+	lastPrune   int64
+}
+
+func newPacketConn2022(c net.PacketConn, ciph *cipher2022) net.PacketConn {
+	const maxPacketSize = 64 * 1024
+	return &packetConn2022{
+		PacketConn: c,
+		cipher2022: ciph,
+		buf:        make([]byte, maxPacketSize),
+		sessions:   make(map[string]*udpSession),
+	}
+}
+
+// session returns the session of the peer if it is still the same one.
+func (c *packetConn2022) session(peer string, clientID []byte) *udpSession {
+	c.sessionLock.Lock()
+	defer c.sessionLock.Unlock()
+	s := c.sessions[peer]
+	if s == nil || (clientID != nil && string(s.clientID) != string(clientID)) {
+		return nil
+	}
+	return s
+}
+
+func (c *packetConn2022) setSession(peer string, s *udpSession) {
+	c.sessionLock.Lock()
+	defer c.sessionLock.Unlock()
+	now := fastime.UnixNanoNow()
+	if now-c.lastPrune > int64(udpSessionTimeout) {
+		for k, v := range c.sessions {
+			if now-atomic.LoadInt64(&v.lastSeen) > int64(udpSessionTimeout) {
+				delete(c.sessions, k)
+			}
+		}
+		c.lastPrune = now
+	}
+	c.sessions[peer] = s
+}
+
+func (c *packetConn2022) newSession(clientID []byte) (*udpSession, error) {
+	s := &udpSession{
+		clientID: append([]byte{}, clientID...),
+		serverID: make([]byte, 8),
+	}
+	if _, err := io.ReadFull(rand.Reader, s.serverID); err != nil {
+		return nil, err
+	}
+	if c.block != nil {
+		var err error
+		if s.recv, err = c.makeAEAD(blake3SessionKey(c.psk, s.clientID)); err != nil {
+			return nil, err
+		}
+		if s.send, err = c.makeAEAD(blake3SessionKey(c.psk, s.serverID)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// unpack decrypts pkt in place and returns the address and payload.
+func (c *packetConn2022) unpack(pkt []byte, peer string) ([]byte, error) {
+	var (
+		clientID, body []byte
+		packetID       uint64
+		s              *udpSession
+		err            error
+	)
+	if c.block != nil {
+		// separate header: session ID and packet ID
+		block, offset := c.block, 16
+		if c.iblock != nil {
+			// the identity header has been checked by the port
+			block, offset = c.iblock, 16+aes.BlockSize
+		}
+		if len(pkt) < offset+16 {
+			return nil, ErrShortPacket
+		}
+		header := pkt[:16]
+		block.Decrypt(header, header)
+		clientID, packetID = header[:8], binary.BigEndian.Uint64(header[8:])
+		if s = c.session(peer, clientID); s == nil {
+			if s, err = c.newSession(clientID); err != nil {
+				return nil, err
+			}
+		}
+		body, err = s.recv.Open(pkt[offset:offset], header[4:16], pkt[offset:], nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if len(pkt) < chacha20poly1305.NonceSizeX+16+c.xaead.Overhead() {
+			return nil, ErrShortPacket
+		}
+		nonce := pkt[:chacha20poly1305.NonceSizeX]
+		body, err = c.xaead.Open(pkt[len(nonce):len(nonce)], nonce, pkt[len(nonce):], nil)
+		if err != nil {
+			return nil, err
+		}
+		clientID, packetID, body = body[:8], binary.BigEndian.Uint64(body[8:]), body[16:]
+		if s = c.session(peer, clientID); s == nil {
+			if s, err = c.newSession(clientID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// main header: type, timestamp and padding
+	if len(body) < 1+8+2 {
+		return nil, ErrShortPacket
+	}
+	if body[0] != headerTypeClientPacket {
+		return nil, ErrBadHeaderType
+	}
+	if !validTimestamp(binary.BigEndian.Uint64(body[1:])) {
+		return nil, ErrBadTimestamp
+	}
+	padding := int(binary.BigEndian.Uint16(body[9:]))
+	if padding > maxPaddingLength || len(body) < 1+8+2+padding {
+		return nil, ErrPaddingExceeded
+	}
+
+	c.sessionLock.Lock()
+	ok := s.filter.check(packetID)
+	c.sessionLock.Unlock()
+	if !ok {
+		return nil, ErrPacketReplayed
+	}
+	atomic.StoreInt64(&s.lastSeen, fastime.UnixNanoNow())
+	c.setSession(peer, s)
+	body = body[1+8+2+padding:]
+	c.User.addTraffic(&c.User.UDPUpload, uint64(len(body)))
+	return body, nil
+}
+
+// pack encrypts address and payload b into dst for the session s.
+func (c *packetConn2022) pack(dst, b []byte, s *udpSession) ([]byte, error) {
+	packetID := atomic.AddUint64(&s.packetID, 1) - 1
+	if c.block != nil {
+		overhead := 16 + 1 + 8 + 8 + 2 + s.send.Overhead()
+		if len(dst) < overhead+len(b) {
+			return nil, io.ErrShortBuffer
+		}
+		header := dst[:16]
+		copy(header, s.serverID)
+		binary.BigEndian.PutUint64(header[8:], packetID)
+		body := dst[16 : 16+1+8+8+2+len(b)]
+		writeServerHeader(body, s.clientID)
+		copy(body[1+8+8+2:], b)
+		body = s.send.Seal(body[:0], header[4:16], body, nil)
+		c.block.Encrypt(header, header)
+		return dst[:16+len(body)], nil
+	}
+
+	nonceSize := chacha20poly1305.NonceSizeX
+	overhead := nonceSize + 16 + 1 + 8 + 8 + 2 + c.xaead.Overhead()
+	if len(dst) < overhead+len(b) {
+		return nil, io.ErrShortBuffer
+	}
+	nonce := dst[:nonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	body := dst[nonceSize : nonceSize+16+1+8+8+2+len(b)]
+	copy(body, s.serverID)
+	binary.BigEndian.PutUint64(body[8:], packetID)
+	writeServerHeader(body[16:], s.clientID)
+	copy(body[16+1+8+8+2:], b)
+	body = c.xaead.Seal(body[:0], nonce, body, nil)
+	return dst[:nonceSize+len(body)], nil
+}
+
+// writeServerHeader writes type, timestamp, client session ID and zero padding length.
+func writeServerHeader(b, clientID []byte) {
+	b[0] = headerTypeServerPacket
+	binary.BigEndian.PutUint64(b[1:], nowTimestamp())
+	copy(b[1+8:], clientID)
+	b[1+8+8], b[1+8+8+1] = 0, 0
+}
+
+// WriteTo encrypts b and write to addr using the embedded PacketConn.
+func (c *packetConn2022) WriteTo(b []byte, addr net.Addr) (int, error) {
+	s := c.session(addr.String(), nil)
+	if s == nil {
+		return 0, errNoSession
+	}
+	c.Lock()
+	defer c.Unlock()
+	buf, err := c.pack(c.buf, b, s)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.PacketConn.WriteTo(buf, addr)
+	c.User.addTraffic(&c.User.UDPDownload, uint64(len(b)))
+	return len(b), err
+}
+
+// ReadFrom reads from the embedded PacketConn and decrypts into b.
+func (c *packetConn2022) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err != nil {
+		return n, addr, err
+	}
+	bb, err := c.unpack(b[:n], addr.String())
+	if err != nil {
+		return n, addr, err
+	}
+	copy(b, bb)
+	return len(bb), addr, err
+}
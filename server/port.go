@@ -0,0 +1,497 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/fastime"
+	reuse "github.com/libp2p/go-reuseport"
+)
+
+var (
+	// ErrIdentityMismatch means the identity PSK differs from the other users of the port.
+	ErrIdentityMismatch = errors.New("identity PSK mismatch on the port")
+	// ErrDuplicateKey means another user of the port has the same key,
+	// the two couldn't be told apart.
+	ErrDuplicateKey = errors.New("key already used on the port")
+	// ErrUnknownUser means no user of the port can decrypt the packet.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrServerClosed means the server is shutting down.
+	ErrServerClosed = errors.New("server closed")
+)
+
+// prober is implemented by ciphers able to tell whether the beginning of
+// a stream is encrypted with them.
+type prober interface {
+	streamProbeSize() int
+	probeStream(b []byte) bool
+}
+
+// packetUnpacker is implemented by the packet conns of every cipher.
+type packetUnpacker interface {
+	net.PacketConn
+	unpack(pkt []byte, peer string) ([]byte, error)
+}
+
+// Port serves every user sharing one address. Users are told apart by
+// their identity headers if they share an identity PSK (2022 Edition),
+// otherwise by trial decryption of the first chunk or packet.
+type Port struct {
+	addr   string
+	Signal chan struct{}
+	tcp    net.Listener
+	udp    net.PacketConn
+
+	lock sync.RWMutex
+	// users is copy-on-write and sorted by probe size
+	users  []*User
+	ipsk   []byte
+	iblock cipher.Block
+	eih    map[[aes.BlockSize]byte]*User
+}
+
+var (
+	portLock sync.Mutex
+	ports    = map[string]*Port{}
+	closing  bool
+)
+
+// join adds u to the port of addr, starting the port if it isn't running.
+func join(addr string, u *User) error {
+	portLock.Lock()
+	defer portLock.Unlock()
+	if closing {
+		return ErrServerClosed
+	}
+	p, ok := ports[addr]
+	if !ok {
+		p = &Port{
+			addr:   addr,
+			Signal: make(chan struct{}),
+			eih:    make(map[[aes.BlockSize]byte]*User),
+		}
+		// bind before the user is reported as started
+		if err := p.listen(); err != nil {
+			return err
+		}
+	}
+	if err := p.add(u); err != nil {
+		if !ok {
+			p.tcp.Close()
+			p.udp.Close()
+		}
+		return err
+	}
+	if !ok {
+		ports[addr] = p
+		go p.NewServer(p.Signal)
+	}
+	u.port = p
+	return nil
+}
+
+// leave removes u from its port and stops the port if nobody is left.
+func leave(u *User) {
+	portLock.Lock()
+	defer portLock.Unlock()
+	p := u.port
+	if p == nil {
+		return
+	}
+	// the port may have been stopped by Shutdown already
+	if p.remove(u) == 0 && ports[p.addr] == p {
+		delete(ports, p.addr)
+		close(p.Signal)
+	}
+	u.port = nil
+}
+
+// Shutdown stops accepting on every port and waits up to drain for the
+// TCP relays in flight, then closes the connections left over.
+func Shutdown(drain time.Duration) {
+	portLock.Lock()
+	closing = true
+	stopped := ports
+	ports = map[string]*Port{}
+	portLock.Unlock()
+
+	for _, p := range stopped {
+		close(p.Signal)
+	}
+	if waitRelays(drain) {
+		return
+	}
+	logf("drain timeout, closing the connections left")
+	for _, p := range stopped {
+		users, _ := p.snapshot()
+		for _, u := range users {
+			u.closeAll()
+		}
+	}
+	// give the relays a chance to account their last bytes
+	waitRelays(relayWait)
+}
+
+// waitRelays reports whether all the TCP relays end within timeout.
+func waitRelays(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&relays) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+func probeSize(u *User) int {
+	if p, ok := u.cipher.(prober); ok {
+		return p.streamProbeSize()
+	}
+	return 0
+}
+
+// cipherKey identifies a cipher by its edition, AEAD and key, the key is
+// nil if unknown.
+type cipherKey struct {
+	is2022 bool
+	aead   uintptr
+	key    []byte
+}
+
+func keyOf(c ConnCipher) cipherKey {
+	switch c := c.(type) {
+	case *cipher2022:
+		return cipherKey{true, reflect.ValueOf(c.makeAEAD).Pointer(), c.psk}
+	case *aeadCipher:
+		if m, ok := c.Cipher.(*metaCipher); ok {
+			return cipherKey{false, reflect.ValueOf(m.makeAEAD).Pointer(), m.psk}
+		}
+	}
+	return cipherKey{}
+}
+
+func (k cipherKey) equal(o cipherKey) bool {
+	return k.key != nil && k.is2022 == o.is2022 && k.aead == o.aead && bytes.Equal(k.key, o.key)
+}
+
+func (p *Port) add(u *User) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	key := keyOf(u.cipher)
+	for _, v := range p.users {
+		if key.equal(keyOf(v.cipher)) {
+			return ErrDuplicateKey
+		}
+	}
+	if c, ok := u.cipher.(*cipher2022); ok && c.ipsk != nil {
+		if p.ipsk == nil {
+			p.ipsk, p.iblock = c.ipsk, c.iblock
+		} else if !bytes.Equal(p.ipsk, c.ipsk) {
+			return ErrIdentityMismatch
+		}
+		p.eih[identityHash(c.psk)] = u
+	}
+	users := make([]*User, 0, len(p.users)+1)
+	i := 0
+	for ; i < len(p.users) && probeSize(p.users[i]) <= probeSize(u); i++ {
+	}
+	users = append(users, p.users[:i]...)
+	users = append(users, u)
+	p.users = append(users, p.users[i:]...)
+	return nil
+}
+
+func (p *Port) remove(u *User) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	users := make([]*User, 0, len(p.users))
+	for _, v := range p.users {
+		if v != u {
+			users = append(users, v)
+		}
+	}
+	p.users = users
+	for k, v := range p.eih {
+		if v == u {
+			delete(p.eih, k)
+		}
+	}
+	if len(p.eih) == 0 {
+		p.ipsk, p.iblock = nil, nil
+	}
+	return len(users)
+}
+
+func (p *Port) snapshot() ([]*User, []byte) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.users, p.ipsk
+}
+
+func (p *Port) has(u *User) bool {
+	users, _ := p.snapshot()
+	for _, v := range users {
+		if v == u {
+			return true
+		}
+	}
+	return false
+}
+
+// streamIdentity looks up the user of identity header eih of a stream,
+// which is encrypted with a subkey derived from the salt.
+func (p *Port) streamIdentity(salt, eih []byte) *User {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.ipsk == nil {
+		return nil
+	}
+	block, err := aes.NewCipher(blake3IdentityKey(p.ipsk, salt))
+	if err != nil {
+		return nil
+	}
+	var id [aes.BlockSize]byte
+	block.Decrypt(id[:], eih)
+	return p.eih[id]
+}
+
+// packetIdentity looks up the user of the identity header of pkt, which is
+// encrypted with the identity PSK and masked by the separate header.
+func (p *Port) packetIdentity(pkt []byte) *User {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.ipsk == nil || len(pkt) < 2*aes.BlockSize {
+		return nil
+	}
+	var header, id [aes.BlockSize]byte
+	p.iblock.Decrypt(header[:], pkt[:aes.BlockSize])
+	p.iblock.Decrypt(id[:], pkt[aes.BlockSize:2*aes.BlockSize])
+	for i := range id {
+		id[i] ^= header[i]
+	}
+	return p.eih[id]
+}
+
+// identify tells which user the stream c belongs to and wraps c with the user's cipher.
+func (p *Port) identify(c net.Conn) (*User, net.Conn) {
+	users, ipsk := p.snapshot()
+	if len(users) == 1 && ipsk == nil {
+		return users[0], users[0].cipher.StreamConn(c)
+	}
+
+	pc := &peekedConn{Conn: c, r: bufio.NewReader(c)}
+	if ipsk != nil {
+		b, err := pc.r.Peek(len(ipsk) + aes.BlockSize)
+		if err != nil {
+			return nil, pc
+		}
+		if u := p.streamIdentity(b[:len(ipsk)], b[len(ipsk):]); u != nil {
+			return u, u.cipher.StreamConn(pc)
+		}
+	}
+	// users are sorted by probe size, so a short first write of the right
+	// user never blocks on a longer probe of somebody else.
+	for _, u := range users {
+		pr, ok := u.cipher.(prober)
+		if !ok {
+			continue
+		}
+		if c, ok := u.cipher.(*cipher2022); ok && c.ipsk != nil {
+			continue
+		}
+		b, err := pc.r.Peek(pr.streamProbeSize())
+		if err != nil {
+			break
+		}
+		if pr.probeStream(b) {
+			return u, u.cipher.StreamConn(pc)
+		}
+	}
+	return nil, pc
+}
+
+// peekedConn reads the bytes peeked during identification first.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// listen binds the TCP and UDP listeners of the port.
+func (p *Port) listen() error {
+	tcpListener, err := reuse.Listen("tcp", p.addr)
+	if err != nil {
+		logf("failed to listen on %s: %v", p.addr, err)
+		return err
+	}
+	udpListener, err := reuse.ListenPacket("udp", p.addr)
+	if err != nil {
+		logf("failed to listen on %s: %v", p.addr, err)
+		tcpListener.Close()
+		return err
+	}
+	p.tcp, p.udp = tcpListener, udpListener
+	return nil
+}
+
+// NewServer serves the listeners bound by listen until portSignal is closed.
+func (p *Port) NewServer(portSignal chan struct{}) {
+	tcpDone := make(chan struct{})
+	udpDone := make(chan struct{})
+
+	go p.udpRemote(udpDone, p.udp)
+	go p.tcpRemote(tcpDone, p.tcp)
+	<-portSignal
+	close(tcpDone)
+	p.tcp.Close()
+	close(udpDone)
+	p.udp.Close()
+}
+
+type udpPeer struct {
+	*User
+	conn     packetUnpacker
+	lastSeen int64
+}
+
+// multiPacketConn demultiplexes packets of the users on a port.
+type multiPacketConn struct {
+	net.PacketConn
+	port      *Port
+	lock      sync.Mutex
+	conns     map[*User]packetUnpacker
+	peers     map[string]*udpPeer
+	lastPrune int64
+	buf       []byte // only used by readFrom
+}
+
+func newMultiPacketConn(c net.PacketConn, p *Port) *multiPacketConn {
+	return &multiPacketConn{
+		PacketConn: c,
+		port:       p,
+		conns:      make(map[*User]packetUnpacker),
+		peers:      make(map[string]*udpPeer),
+		buf:        make([]byte, udpBufSize),
+	}
+}
+
+func (c *multiPacketConn) conn(u *User) packetUnpacker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pc, ok := c.conns[u]
+	if !ok {
+		pc, _ = u.cipher.PacketConn(c.PacketConn).(packetUnpacker)
+		c.conns[u] = pc
+	}
+	return pc
+}
+
+func (c *multiPacketConn) peer(key string) *udpPeer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.peers[key]
+}
+
+func (c *multiPacketConn) setPeer(key string, u *User, pc packetUnpacker) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := fastime.UnixNanoNow()
+	if now-c.lastPrune > int64(udpSessionTimeout) {
+		for k, v := range c.peers {
+			if now-atomic.LoadInt64(&v.lastSeen) > int64(udpSessionTimeout) {
+				delete(c.peers, k)
+			}
+		}
+		for k := range c.conns {
+			if !c.port.has(k) {
+				delete(c.conns, k)
+			}
+		}
+		c.lastPrune = now
+	}
+	p, ok := c.peers[key]
+	if !ok || p.User != u {
+		p = &udpPeer{User: u, conn: pc}
+		c.peers[key] = p
+	}
+	atomic.StoreInt64(&p.lastSeen, now)
+}
+
+// try decrypts a copy of pkt as u, leaving pkt untouched on failure.
+func (c *multiPacketConn) try(u *User, pkt []byte, key string) ([]byte, packetUnpacker, error) {
+	pc := c.conn(u)
+	if pc == nil {
+		return nil, nil, ErrCipherNotSupported
+	}
+	buf := c.buf[:copy(c.buf, pkt)]
+	bb, err := pc.unpack(buf, key)
+	return bb, pc, err
+}
+
+// readFrom reads a packet into b and tells which user it belongs to.
+func (c *multiPacketConn) readFrom(b []byte) (int, net.Addr, *User, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err != nil {
+		return n, addr, nil, err
+	}
+	pkt, key := b[:n], addr.String()
+	users, ipsk := c.port.snapshot()
+
+	var tried *User
+	if p := c.peer(key); p != nil && c.port.has(p.User) {
+		tried = p.User
+		if bb, pc, err := c.try(p.User, pkt, key); err == nil {
+			c.setPeer(key, p.User, pc)
+			return copy(b, bb), addr, p.User, nil
+		}
+	}
+	if ipsk != nil {
+		if u := c.port.packetIdentity(pkt); u != nil && u != tried {
+			if bb, pc, err := c.try(u, pkt, key); err == nil {
+				c.setPeer(key, u, pc)
+				return copy(b, bb), addr, u, nil
+			}
+		}
+	}
+	for _, u := range users {
+		if u == tried {
+			continue
+		}
+		if ciph, ok := u.cipher.(*cipher2022); ok && ciph.ipsk != nil {
+			continue
+		}
+		if bb, pc, err := c.try(u, pkt, key); err == nil {
+			c.setPeer(key, u, pc)
+			return copy(b, bb), addr, u, nil
+		}
+	}
+	return n, addr, nil, ErrUnknownUser
+}
+
+// ReadFrom reads from the embedded PacketConn and decrypts into b.
+func (c *multiPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, _, err := c.readFrom(b)
+	return n, addr, err
+}
+
+// WriteTo encrypts b for the user of addr.
+func (c *multiPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	p := c.peer(addr.String())
+	if p == nil {
+		return 0, errNoSession
+	}
+	if !p.User.allowDownload(len(b)) {
+		return len(b), nil
+	}
+	return p.conn.WriteTo(b, addr)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"crypto/aes"
+	"testing"
+)
+
+func TestPortAddDuplicateKey(t *testing.T) {
+	user := func(cipher, password string) *User {
+		u := &User{}
+		c, err := PickCipher(cipher, nil, password, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.cipher = c
+		return u
+	}
+	const psk = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	const ipsk = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	p := &Port{eih: make(map[[aes.BlockSize]byte]*User)}
+	for _, u := range []*User{
+		user("AES-256-GCM", "secret"),
+		user("CHACHA20-IETF-POLY1305", "secret"),
+		user("AES-256-GCM", "other"),
+		user("2022-BLAKE3-AES-256-GCM", ipsk+":"+psk),
+	} {
+		if err := p.add(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, u := range []*User{
+		user("AES-256-GCM", "secret"),
+		user("2022-BLAKE3-AES-256-GCM", ipsk+":"+psk),
+	} {
+		if err := p.add(u); err != ErrDuplicateKey {
+			t.Fatalf("got %v, want %v", err, ErrDuplicateKey)
+		}
+	}
+	if len(p.users) != 4 {
+		t.Fatalf("%d users on the port, want 4", len(p.users))
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/kpango/fastime"
+	"golang.org/x/time/rate"
+)
+
+// minBurst lets a whole chunk or packet through at once.
+const minBurst = udpBufSize
+
+func newLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Inf, minBurst)
+}
+
+func setLimit(l *rate.Limiter, bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	burst := int(bytesPerSec)
+	if burst < minBurst {
+		burst = minBurst
+	}
+	l.SetBurst(burst)
+	l.SetLimit(rate.Limit(bytesPerSec))
+}
+
+func getLimit(l *rate.Limiter) int64 {
+	if l.Limit() == rate.Inf {
+		return 0
+	}
+	return int64(l.Limit())
+}
+
+// waitN blocks until n bytes are allowed by l, or ctx is done.
+func waitN(ctx context.Context, l *rate.Limiter, n int) error {
+	for n > 0 {
+		m := n
+		if b := l.Burst(); m > b {
+			m = b
+		}
+		if err := l.WaitN(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// the burst was lowered meanwhile
+			continue
+		}
+		n -= m
+	}
+	return nil
+}
+
+// SetRateLimit limits the upload and download bandwidth in bytes per second,
+// shared by all connections of the user. 0 means unlimited.
+func (u *User) SetRateLimit(up, down int64) {
+	setLimit(u.upLimiter, up)
+	setLimit(u.downLimiter, down)
+}
+
+func (u *User) GetRateLimit() (int64, int64) {
+	return getLimit(u.upLimiter), getLimit(u.downLimiter)
+}
+
+// UDP packets over the limit are dropped instead of waiting,
+// so that one user can't stall the port.
+func (u *User) allowUpload(n int) bool {
+	return u.upLimiter.AllowN(fastime.Now(), n)
+}
+
+func (u *User) allowDownload(n int) bool {
+	return u.downLimiter.AllowN(fastime.Now(), n)
+}
+
+// limitedConn throttles reads from and writes to the client. The waits
+// end when the conn is closed or the user tears down its connections.
+type limitedConn struct {
+	net.Conn
+	u      *User
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (u *User) limitConn(c net.Conn) net.Conn {
+	u.lock.Lock()
+	ctx, cancel := context.WithCancel(u.ctx)
+	u.lock.Unlock()
+	return &limitedConn{Conn: c, u: u, ctx: ctx, cancel: cancel}
+}
+
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if werr := waitN(c.ctx, c.u.upLimiter, n); err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+func (c *limitedConn) Write(b []byte) (int, error) {
+	var n int
+	for len(b) > 0 {
+		m := len(b)
+		if burst := c.u.downLimiter.Burst(); m > burst {
+			m = burst
+		}
+		if err := waitN(c.ctx, c.u.downLimiter, m); err != nil {
+			return n, err
+		}
+		nw, err := c.Conn.Write(b[:m])
+		n += nw
+		if err != nil {
+			return n, err
+		}
+		b = b[m:]
+	}
+	return n, nil
+}
+
+func (c *limitedConn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitedConnTeardown(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		teardown func(u *User, c net.Conn)
+	}{
+		{"closeAll", func(u *User, _ net.Conn) { u.closeAll() }},
+		{"Shutdown", func(u *User, _ net.Conn) { u.Shutdown() }},
+		{"Close", func(_ *User, c net.Conn) { c.Close() }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u := newUser()
+			u.SetRateLimit(0, minBurst)
+			left, right := net.Pipe()
+			defer right.Close()
+			go io.Copy(io.Discard, right)
+			c := u.limitConn(left)
+
+			// the second burst waits for a second
+			done := make(chan error, 1)
+			go func() {
+				_, err := c.Write(make([]byte, 2*minBurst))
+				done <- err
+			}()
+			time.Sleep(100 * time.Millisecond)
+			tc.teardown(u, c)
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("write ended with %v", err)
+				}
+			case <-time.After(500 * time.Millisecond):
+				t.Fatal("write still waiting after the teardown")
+			}
+		})
+	}
+}
+
+func TestLimitedConnAfterCloseAll(t *testing.T) {
+	u := newUser()
+	u.closeAll()
+	left, right := net.Pipe()
+	defer right.Close()
+	go io.Copy(io.Discard, right)
+	if _, err := u.limitConn(left).Write([]byte("x")); err != nil {
+		t.Fatalf("conn made after closeAll: %v", err)
+	}
+}
@@ -1,286 +1,313 @@
-package server
-
-import (
-	"bytes"
-	"crypto/cipher"
-	"crypto/rand"
-	"io"
-	"net"
-	"sync/atomic"
-)
-
-// payloadSizeMask is the maximum size of payload in bytes.
-const payloadSizeMask = 0x3FFF // 16*1024 - 1
-
-type writer struct {
-	io.Writer
-	cipher.AEAD
-	*User
-	nonce []byte
-	buf   []byte
-}
-
-// NewWriter wraps an io.Writer with AEAD encryption.
-func NewWriter(w io.Writer, aead cipher.AEAD, u *User) io.Writer { return newWriter(w, aead, u) }
-
-func newWriter(w io.Writer, aead cipher.AEAD, u *User) *writer {
-	return &writer{
-		Writer: w,
-		AEAD:   aead,
-		User:   u,
-		buf:    make([]byte, 2+aead.Overhead()+payloadSizeMask+aead.Overhead()),
-		nonce:  make([]byte, aead.NonceSize()),
-	}
-}
-
-// Write encrypts b and writes to the embedded io.Writer.
-func (w *writer) Write(b []byte) (int, error) {
-	n, err := w.ReadFrom(bytes.NewBuffer(b))
-	return int(n), err
-}
-
-// ReadFrom reads from the given io.Reader until EOF or error, encrypts and
-// writes to the embedded io.Writer. Returns number of bytes read from r and
-// any error encountered.
-func (w *writer) ReadFrom(r io.Reader) (n int64, err error) {
-	for {
-		buf := w.buf
-		payloadBuf := buf[2+w.Overhead() : 2+w.Overhead()+payloadSizeMask]
-		nr, er := r.Read(payloadBuf)
-
-		if nr > 0 {
-			//n += int64(nr)
-			_ = atomic.AddUint64(&w.User.Traffic, uint64(nr))
-			buf = buf[:2+w.Overhead()+nr+w.Overhead()]
-			payloadBuf = payloadBuf[:nr]
-			buf[0], buf[1] = byte(nr>>8), byte(nr) // big-endian payload size
-			w.Seal(buf[:0], w.nonce, buf[:2], nil)
-			increment(w.nonce)
-
-			w.Seal(payloadBuf[:0], w.nonce, payloadBuf, nil)
-			increment(w.nonce)
-
-			_, ew := w.Writer.Write(buf)
-			if ew != nil {
-				err = ew
-				break
-			}
-		}
-
-		if er != nil {
-			if er != io.EOF { // ignore EOF as per io.ReaderFrom contract
-				err = er
-			}
-			break
-		}
-	}
-
-	return n, err
-}
-
-type reader struct {
-	io.Reader
-	cipher.AEAD
-	*User
-	nonce    []byte
-	buf      []byte
-	leftover []byte
-}
-
-// NewReader wraps an io.Reader with AEAD decryption.
-func NewReader(r io.Reader, aead cipher.AEAD, u *User) io.Reader { return newReader(r, aead, u) }
-
-func newReader(r io.Reader, aead cipher.AEAD, u *User) *reader {
-	return &reader{
-		Reader: r,
-		AEAD:   aead,
-		User:   u,
-		buf:    make([]byte, payloadSizeMask+aead.Overhead()),
-		nonce:  make([]byte, aead.NonceSize()),
-	}
-}
-
-// read and decrypt a record into the internal buffer. Return decrypted payload length and any error encountered.
-func (r *reader) read() (int, error) {
-	// decrypt payload size
-	buf := r.buf[:2+r.Overhead()]
-	_, err := io.ReadFull(r.Reader, buf)
-	if err != nil {
-		return 0, err
-	}
-
-	_, err = r.Open(buf[:0], r.nonce, buf, nil)
-	increment(r.nonce)
-	if err != nil {
-		return 0, err
-	}
-
-	size := (int(buf[0])<<8 + int(buf[1])) & payloadSizeMask
-
-	// decrypt payload
-	buf = r.buf[:size+r.Overhead()]
-	_, err = io.ReadFull(r.Reader, buf)
-	if err != nil {
-		return 0, err
-	}
-
-	_, err = r.Open(buf[:0], r.nonce, buf, nil)
-	increment(r.nonce)
-	if err != nil {
-		return 0, err
-	}
-
-	return size, nil
-}
-
-// Read reads from the embedded io.Reader, decrypts and writes to b.
-func (r *reader) Read(b []byte) (int, error) {
-	// copy decrypted bytes (if any) from previous record first
-	if len(r.leftover) > 0 {
-		n := copy(b, r.leftover)
-		r.leftover = r.leftover[n:]
-		_ = atomic.AddUint64(&r.User.Traffic, uint64(n))
-		return n, nil
-	}
-
-	n, err := r.read()
-	m := copy(b, r.buf[:n])
-	if m < n { // insufficient len(b), keep leftover for next read
-		r.leftover = r.buf[m:n]
-	}
-	_ = atomic.AddUint64(&r.User.Traffic, uint64(m))
-	return m, err
-}
-
-// WriteTo reads from the embedded io.Reader, decrypts and writes to w until
-// there's no more data to write or when an error occurs. Return number of
-// bytes written to w and any error encountered.
-func (r *reader) WriteTo(w io.Writer) (n int64, err error) {
-	// write decrypted bytes left over from previous record
-	for len(r.leftover) > 0 {
-		nw, ew := w.Write(r.leftover)
-		r.leftover = r.leftover[nw:]
-		//n += int64(nw)
-		_ = atomic.AddUint64(&r.User.Traffic, uint64(nw))
-		if ew != nil {
-			return n, ew
-		}
-	}
-
-	for {
-		nr, er := r.read()
-		if nr > 0 {
-			nw, ew := w.Write(r.buf[:nr])
-			//n += int64(nw)
-			_ = atomic.AddUint64(&r.User.Traffic, uint64(nw))
-			if ew != nil {
-				err = ew
-				break
-			}
-		}
-
-		if er != nil {
-			if er != io.EOF { // ignore EOF as per io.Copy contract (using src.WriteTo shortcut)
-				err = er
-			}
-			break
-		}
-	}
-
-	return n, err
-}
-
-// increment little-endian encoded unsigned integer b. Wrap around on overflow.
-func increment(b []byte) {
-	for i := range b {
-		b[i]++
-		if b[i] != 0 {
-			return
-		}
-	}
-}
-
-type streamConn struct {
-	net.Conn
-	Cipher
-	*User
-	r *reader
-	w *writer
-}
-
-func (c *streamConn) initReader() error {
-	salt := make([]byte, c.SaltSize())
-	if _, err := io.ReadFull(c.Conn, salt); err != nil {
-		return err
-	}
-	aead, err := c.Decrypter(salt)
-	if err != nil {
-		return err
-	}
-
-	if CheckSalt(salt) {
-		return ErrRepeatedSalt
-	}
-
-	c.r = newReader(c.Conn, aead, c.User)
-	return nil
-}
-
-func (c *streamConn) Read(b []byte) (int, error) {
-	if c.r == nil {
-		if err := c.initReader(); err != nil {
-			return 0, err
-		}
-	}
-	return c.r.Read(b)
-}
-
-func (c *streamConn) WriteTo(w io.Writer) (int64, error) {
-	if c.r == nil {
-		if err := c.initReader(); err != nil {
-			return 0, err
-		}
-	}
-	return c.r.WriteTo(w)
-}
-
-func (c *streamConn) initWriter() error {
-	salt := make([]byte, c.SaltSize())
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return err
-	}
-	aead, err := c.Encrypter(salt)
-	if err != nil {
-		return err
-	}
-	_, err = c.Conn.Write(salt)
-	if err != nil {
-		return err
-	}
-	AddSalt(salt)
-	c.w = newWriter(c.Conn, aead, c.User)
-	return nil
-}
-
-func (c *streamConn) Write(b []byte) (int, error) {
-	if c.w == nil {
-		if err := c.initWriter(); err != nil {
-			return 0, err
-		}
-	}
-	return c.w.Write(b)
-}
-
-func (c *streamConn) ReadFrom(r io.Reader) (int64, error) {
-	if c.w == nil {
-		if err := c.initWriter(); err != nil {
-			return 0, err
-		}
-	}
-	return c.w.ReadFrom(r)
-}
-
-// NewConn wraps a stream-oriented net.Conn with cipher.
-func NewConn(c net.Conn, ciph Cipher, u *User) net.Conn {
-	return &streamConn{Conn: c, Cipher: ciph, User: u}
-}
+package server
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"net"
+)
+
+// payloadSizeMask is the maximum size of payload in bytes.
+const payloadSizeMask = 0x3FFF // 16*1024 - 1
+
+type writer struct {
+	io.Writer
+	cipher.AEAD
+	*User
+	nonce    []byte
+	buf      []byte
+	sizeMask int
+	header   []byte // sealed together with the size of the first chunk
+}
+
+// NewWriter wraps an io.Writer with AEAD encryption.
+func NewWriter(w io.Writer, aead cipher.AEAD, u *User) io.Writer { return newWriter(w, aead, u) }
+
+func newWriter(w io.Writer, aead cipher.AEAD, u *User) *writer {
+	return newWriterSize(w, aead, u, payloadSizeMask)
+}
+
+func newWriterSize(w io.Writer, aead cipher.AEAD, u *User, sizeMask int) *writer {
+	return &writer{
+		Writer:   w,
+		AEAD:     aead,
+		User:     u,
+		buf:      make([]byte, 2+aead.Overhead()+sizeMask+aead.Overhead()),
+		nonce:    make([]byte, aead.NonceSize()),
+		sizeMask: sizeMask,
+	}
+}
+
+// Write encrypts b and writes to the embedded io.Writer.
+func (w *writer) Write(b []byte) (int, error) {
+	n, err := w.ReadFrom(bytes.NewBuffer(b))
+	return int(n), err
+}
+
+// ReadFrom reads from the given io.Reader until EOF or error, encrypts and
+// writes to the embedded io.Writer. Returns number of bytes read from r and
+// any error encountered.
+func (w *writer) ReadFrom(r io.Reader) (n int64, err error) {
+	for {
+		buf := w.buf
+		payloadBuf := buf[2+w.Overhead() : 2+w.Overhead()+w.sizeMask]
+		nr, er := r.Read(payloadBuf)
+
+		if nr > 0 {
+			//n += int64(nr)
+			w.User.addTraffic(&w.User.TCPDownload, uint64(nr))
+			buf = buf[:2+w.Overhead()+nr+w.Overhead()]
+			payloadBuf = payloadBuf[:nr]
+			if w.header != nil {
+				// the size of the first chunk lives in the sealed header
+				header := append(w.header, byte(nr>>8), byte(nr))
+				w.header = nil
+				header = w.Seal(header[:0], w.nonce, header, nil)
+				increment(w.nonce)
+				w.Seal(payloadBuf[:0], w.nonce, payloadBuf, nil)
+				increment(w.nonce)
+				_, ew := w.Writer.Write(append(header, buf[2+w.Overhead():]...))
+				if ew != nil {
+					err = ew
+					break
+				}
+				continue
+			}
+			buf[0], buf[1] = byte(nr>>8), byte(nr) // big-endian payload size
+			w.Seal(buf[:0], w.nonce, buf[:2], nil)
+			increment(w.nonce)
+
+			w.Seal(payloadBuf[:0], w.nonce, payloadBuf, nil)
+			increment(w.nonce)
+
+			_, ew := w.Writer.Write(buf)
+			if ew != nil {
+				err = ew
+				break
+			}
+		}
+
+		if er != nil {
+			if er != io.EOF { // ignore EOF as per io.ReaderFrom contract
+				err = er
+			}
+			break
+		}
+	}
+
+	return n, err
+}
+
+type reader struct {
+	io.Reader
+	cipher.AEAD
+	*User
+	nonce    []byte
+	buf      []byte
+	leftover []byte
+	sizeMask int
+}
+
+// NewReader wraps an io.Reader with AEAD decryption.
+func NewReader(r io.Reader, aead cipher.AEAD, u *User) io.Reader { return newReader(r, aead, u) }
+
+func newReader(r io.Reader, aead cipher.AEAD, u *User) *reader {
+	return newReaderSize(r, aead, u, payloadSizeMask)
+}
+
+func newReaderSize(r io.Reader, aead cipher.AEAD, u *User, sizeMask int) *reader {
+	return &reader{
+		Reader:   r,
+		AEAD:     aead,
+		User:     u,
+		buf:      make([]byte, sizeMask+aead.Overhead()),
+		nonce:    make([]byte, aead.NonceSize()),
+		sizeMask: sizeMask,
+	}
+}
+
+// read and decrypt a record into the internal buffer. Return decrypted payload length and any error encountered.
+func (r *reader) read() (int, error) {
+	// decrypt payload size
+	buf := r.buf[:2+r.Overhead()]
+	_, err := io.ReadFull(r.Reader, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.Open(buf[:0], r.nonce, buf, nil)
+	increment(r.nonce)
+	if err != nil {
+		return 0, err
+	}
+
+	size := (int(buf[0])<<8 + int(buf[1])) & r.sizeMask
+
+	// decrypt payload
+	buf = r.buf[:size+r.Overhead()]
+	_, err = io.ReadFull(r.Reader, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.Open(buf[:0], r.nonce, buf, nil)
+	increment(r.nonce)
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// Read reads from the embedded io.Reader, decrypts and writes to b.
+func (r *reader) Read(b []byte) (int, error) {
+	// copy decrypted bytes (if any) from previous record first
+	if len(r.leftover) > 0 {
+		n := copy(b, r.leftover)
+		r.leftover = r.leftover[n:]
+		r.User.addTraffic(&r.User.TCPUpload, uint64(n))
+		return n, nil
+	}
+
+	n, err := r.read()
+	m := copy(b, r.buf[:n])
+	if m < n { // insufficient len(b), keep leftover for next read
+		r.leftover = r.buf[m:n]
+	}
+	r.User.addTraffic(&r.User.TCPUpload, uint64(m))
+	return m, err
+}
+
+// WriteTo reads from the embedded io.Reader, decrypts and writes to w until
+// there's no more data to write or when an error occurs. Return number of
+// bytes written to w and any error encountered.
+func (r *reader) WriteTo(w io.Writer) (n int64, err error) {
+	// write decrypted bytes left over from previous record
+	for len(r.leftover) > 0 {
+		nw, ew := w.Write(r.leftover)
+		r.leftover = r.leftover[nw:]
+		//n += int64(nw)
+		r.User.addTraffic(&r.User.TCPUpload, uint64(nw))
+		if ew != nil {
+			return n, ew
+		}
+	}
+
+	for {
+		nr, er := r.read()
+		if nr > 0 {
+			nw, ew := w.Write(r.buf[:nr])
+			//n += int64(nw)
+			r.User.addTraffic(&r.User.TCPUpload, uint64(nw))
+			if ew != nil {
+				err = ew
+				break
+			}
+		}
+
+		if er != nil {
+			if er != io.EOF { // ignore EOF as per io.Copy contract (using src.WriteTo shortcut)
+				err = er
+			}
+			break
+		}
+	}
+
+	return n, err
+}
+
+// increment little-endian encoded unsigned integer b. Wrap around on overflow.
+func increment(b []byte) {
+	for i := range b {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
+
+type streamConn struct {
+	net.Conn
+	Cipher
+	*User
+	r *reader
+	w *writer
+}
+
+func (c *streamConn) initReader() error {
+	salt := make([]byte, c.SaltSize())
+	if _, err := io.ReadFull(c.Conn, salt); err != nil {
+		return err
+	}
+	aead, err := c.Decrypter(salt)
+	if err != nil {
+		return err
+	}
+
+	if CheckSalt(salt) {
+		return ErrRepeatedSalt
+	}
+
+	c.r = newReader(c.Conn, aead, c.User)
+	return nil
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	if c.r == nil {
+		if err := c.initReader(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.Read(b)
+}
+
+func (c *streamConn) WriteTo(w io.Writer) (int64, error) {
+	if c.r == nil {
+		if err := c.initReader(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.WriteTo(w)
+}
+
+func (c *streamConn) initWriter() error {
+	salt := make([]byte, c.SaltSize())
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	aead, err := c.Encrypter(salt)
+	if err != nil {
+		return err
+	}
+	_, err = c.Conn.Write(salt)
+	if err != nil {
+		return err
+	}
+	AddSalt(salt)
+	c.w = newWriter(c.Conn, aead, c.User)
+	return nil
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	if c.w == nil {
+		if err := c.initWriter(); err != nil {
+			return 0, err
+		}
+	}
+	return c.w.Write(b)
+}
+
+func (c *streamConn) ReadFrom(r io.Reader) (int64, error) {
+	if c.w == nil {
+		if err := c.initWriter(); err != nil {
+			return 0, err
+		}
+	}
+	return c.w.ReadFrom(r)
+}
+
+// NewConn wraps a stream-oriented net.Conn with cipher.
+func NewConn(c net.Conn, ciph Cipher, u *User) net.Conn {
+	return &streamConn{Conn: c, Cipher: ciph, User: u}
+}
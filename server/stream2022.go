@@ -0,0 +1,159 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/BishiNET/ss-server/socks"
+)
+
+var errNoRequestSalt = errors.New("response before request")
+
+type streamConn2022 struct {
+	net.Conn
+	*cipher2022
+	r    *reader
+	w    *writer
+	salt []byte // request salt, carried back in the response header
+}
+
+func (c *streamConn2022) initReader() error {
+	salt := make([]byte, c.SaltSize())
+	if _, err := io.ReadFull(c.Conn, salt); err != nil {
+		return err
+	}
+	aead, err := c.Decrypter(salt)
+	if err != nil {
+		return err
+	}
+
+	if CheckSalt(salt) {
+		return ErrRepeatedSalt
+	}
+
+	if c.ipsk != nil {
+		// the identity header has been checked by the port
+		if _, err := io.ReadFull(c.Conn, make([]byte, aes.BlockSize)); err != nil {
+			return err
+		}
+	}
+
+	r := newReaderSize(c.Conn, aead, c.User, payloadSizeMask2022)
+
+	// fixed-length header: type, timestamp and length of the variable-length header
+	buf := r.buf[:1+8+2+aead.Overhead()]
+	if _, err := io.ReadFull(c.Conn, buf); err != nil {
+		return err
+	}
+	_, err = aead.Open(buf[:0], r.nonce, buf, nil)
+	increment(r.nonce)
+	if err != nil {
+		return err
+	}
+	if buf[0] != headerTypeClientStream {
+		return ErrBadHeaderType
+	}
+	if !validTimestamp(binary.BigEndian.Uint64(buf[1:])) {
+		return ErrBadTimestamp
+	}
+	size := int(binary.BigEndian.Uint16(buf[9:]))
+
+	// variable-length header: address, padding and initial payload
+	buf = r.buf[:size+aead.Overhead()]
+	if _, err := io.ReadFull(c.Conn, buf); err != nil {
+		return err
+	}
+	_, err = aead.Open(buf[:0], r.nonce, buf, nil)
+	increment(r.nonce)
+	if err != nil {
+		return err
+	}
+	AddSalt(salt)
+
+	buf = buf[:size]
+	addr := socks.SplitAddr(buf)
+	if addr == nil || len(buf) < len(addr)+2 {
+		return socks.ErrAddressNotSupported
+	}
+	rest := buf[len(addr):]
+	padding := int(binary.BigEndian.Uint16(rest))
+	if padding > maxPaddingLength || len(rest) < 2+padding {
+		return ErrPaddingExceeded
+	}
+	// leave the address and the initial payload for socks.ReadAddr and relay
+	n := copy(rest, rest[2+padding:])
+	r.leftover = buf[:len(addr)+n]
+
+	c.salt = salt
+	c.r = r
+	return nil
+}
+
+func (c *streamConn2022) Read(b []byte) (int, error) {
+	if c.r == nil {
+		if err := c.initReader(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.Read(b)
+}
+
+func (c *streamConn2022) WriteTo(w io.Writer) (int64, error) {
+	if c.r == nil {
+		if err := c.initReader(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.WriteTo(w)
+}
+
+func (c *streamConn2022) initWriter() error {
+	if c.salt == nil {
+		return errNoRequestSalt
+	}
+	salt := make([]byte, c.SaltSize())
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	aead, err := c.Encrypter(salt)
+	if err != nil {
+		return err
+	}
+	_, err = c.Conn.Write(salt)
+	if err != nil {
+		return err
+	}
+	AddSalt(salt)
+
+	w := newWriterSize(c.Conn, aead, c.User, payloadSizeMask2022)
+	// fixed-length header: type, timestamp, request salt and length of the first chunk
+	header := make([]byte, 1+8+len(c.salt), 1+8+len(c.salt)+2+aead.Overhead())
+	header[0] = headerTypeServerStream
+	binary.BigEndian.PutUint64(header[1:], nowTimestamp())
+	copy(header[1+8:], c.salt)
+	w.header = header
+	c.w = w
+	return nil
+}
+
+func (c *streamConn2022) Write(b []byte) (int, error) {
+	if c.w == nil {
+		if err := c.initWriter(); err != nil {
+			return 0, err
+		}
+	}
+	return c.w.Write(b)
+}
+
+func (c *streamConn2022) ReadFrom(r io.Reader) (int64, error) {
+	if c.w == nil {
+		if err := c.initWriter(); err != nil {
+			return 0, err
+		}
+	}
+	return c.w.ReadFrom(r)
+}
@@ -1,120 +1,258 @@
-package server
-
-import (
-	"fmt"
-	"log"
-	"sort"
-	"strings"
-	"sync"
-	"sync/atomic"
-
-	reuse "github.com/libp2p/go-reuseport"
-)
-
-type User struct {
-	Traffic       uint64
-	UsedMilliTime int64
-	Signal        chan struct{}
-	lock          sync.Mutex
-}
-
-var (
-	availableCipher = []string{
-		"AES-128-GCM", "AES-256-GCM", "CHACHA20-IETF-POLY1305",
-	}
-)
-
-func checkCipher(cipher string) bool {
-	cipher = strings.ToUpper(cipher)
-	i := sort.SearchStrings(availableCipher, cipher)
-	if i < len(availableCipher) && availableCipher[i] == cipher {
-		return true
-	}
-	return false
-}
-
-func New(cipher, addr, password string) (*User, error) {
-	//Check Cipher
-	if !checkCipher(cipher) {
-		return nil, fmt.Errorf("invalid cipher")
-	}
-	sig := make(chan struct{})
-	user := &User{
-		Signal: sig,
-	}
-	go user.NewServer(sig, cipher, addr, password)
-	return user, nil
-}
-
-func (u *User) NewServer(userSignal chan struct{}, cipher, addr, password string) {
-	tcpDone := make(chan struct{})
-	udpDone := make(chan struct{})
-	tcpListener, err1 := reuse.Listen("tcp", addr)
-	udpListener, err2 := reuse.ListenPacket("udp", addr)
-
-	if err1 != nil || err2 != nil {
-		logf("failed to listen on %s: ", addr)
-		return
-	}
-
-	ciph, err := PickCipher(cipher, nil, password, u)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	go u.udpRemote(udpDone, udpListener, ciph.PacketConn)
-	go u.tcpRemote(tcpDone, tcpListener, ciph.StreamConn)
-	<-userSignal
-	close(tcpDone)
-	tcpListener.Close()
-	close(udpDone)
-	udpListener.Close()
-
-}
-
-func (u *User) Shutdown() {
-	close(u.Signal)
-}
-
-// These methods are THREAD-SAFE.
-// Nevermind using them.
-func (u *User) Reset() {
-	atomic.StoreUint64(&u.Traffic, 0)
-	atomic.StoreInt64(&u.UsedMilliTime, 0)
-}
-func (u *User) ResetTraffic() {
-	atomic.StoreUint64(&u.Traffic, 0)
-}
-
-func (u *User) ResetTime() {
-	atomic.StoreInt64(&u.UsedMilliTime, 0)
-}
-
-// just keep it for futher uses
-func (u *User) AddToTraffic(traffic int64) {
-	_ = atomic.AddUint64(&u.Traffic, uint64(traffic))
-}
-
-func (u *User) AddToTime(MilliTime int64) {
-	_ = atomic.AddInt64(&u.UsedMilliTime, MilliTime)
-}
-
-func (u *User) AddToTrafficInt(traffic int) {
-	_ = atomic.AddUint64(&u.Traffic, uint64(traffic))
-}
-
-func (u *User) Set(traffic uint64, usedtime int64) {
-	atomic.StoreUint64(&u.Traffic, traffic)
-	atomic.StoreInt64(&u.UsedMilliTime, usedtime)
-}
-func (u *User) Get() (uint64, int64) {
-	return atomic.LoadUint64(&u.Traffic), atomic.LoadInt64(&u.UsedMilliTime)
-}
-
-func (u *User) GetTraffic() uint64 {
-	return atomic.LoadUint64(&u.Traffic)
-}
-
-func (u *User) GetUsedTime() int64 {
-	return atomic.LoadInt64(&u.UsedMilliTime)
-}
+package server
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+type User struct {
+	// Traffic is the total of the directional counters below.
+	Traffic       uint64
+	UsedMilliTime int64
+	TCPUpload     uint64
+	TCPDownload   uint64
+	UDPUpload     uint64
+	UDPDownload   uint64
+	// Quota is the traffic limit in bytes, 0 means unlimited.
+	Quota uint64
+	// Remote is the traffic of the user on the other nodes, counted
+	// against the quota.
+	Remote uint64
+	Signal chan struct{}
+	lock   sync.Mutex
+	cipher ConnCipher
+	port   *Port
+	// conns are the live connections torn down once the quota is used up.
+	conns map[io.Closer]struct{}
+	// bandwidth limits shared by all connections
+	upLimiter   *rate.Limiter
+	downLimiter *rate.Limiter
+	// ctx is cancelled by closeAll and Shutdown to stop the connections
+	// waiting on the limits, closeAll starts a new one.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var (
+	// sorted for binary search
+	availableCipher = []string{
+		"2022-BLAKE3-AES-128-GCM", "2022-BLAKE3-AES-256-GCM", "2022-BLAKE3-CHACHA20-POLY1305",
+		"AES-128-GCM", "AES-256-GCM", "CHACHA20-IETF-POLY1305",
+	}
+)
+
+func checkCipher(cipher string) bool {
+	cipher = strings.ToUpper(cipher)
+	i := sort.SearchStrings(availableCipher, cipher)
+	if i < len(availableCipher) && availableCipher[i] == cipher {
+		return true
+	}
+	return false
+}
+
+// CheckCipher reports whether a user can be created with cipher and
+// password, without creating it.
+func CheckCipher(cipher, password string) error {
+	if !checkCipher(cipher) {
+		return ErrCipherNotSupported
+	}
+	_, err := PickCipher(cipher, nil, password, nil)
+	return err
+}
+
+// New creates a user and serves it on addr.
+// Users created with the same addr share one listener, which is bound
+// before New returns so that bind errors reach the caller.
+func New(cipher, addr, password string) (*User, error) {
+	//Check Cipher
+	if !checkCipher(cipher) {
+		return nil, ErrCipherNotSupported
+	}
+	user := newUser()
+	ciph, err := PickCipher(cipher, nil, password, user)
+	if err != nil {
+		return nil, err
+	}
+	user.cipher = ciph
+	if err := join(addr, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func newUser() *User {
+	u := &User{
+		Signal:      make(chan struct{}),
+		conns:       make(map[io.Closer]struct{}),
+		upLimiter:   newLimiter(),
+		downLimiter: newLimiter(),
+	}
+	u.ctx, u.cancel = context.WithCancel(cb)
+	return u
+}
+
+func (u *User) Shutdown() {
+	close(u.Signal)
+	u.lock.Lock()
+	u.cancel()
+	u.lock.Unlock()
+	leave(u)
+}
+
+// These methods are THREAD-SAFE.
+// Nevermind using them.
+func (u *User) Reset() {
+	u.ResetTraffic()
+	atomic.StoreInt64(&u.UsedMilliTime, 0)
+}
+func (u *User) ResetTraffic() {
+	atomic.StoreUint64(&u.Traffic, 0)
+	atomic.StoreUint64(&u.Remote, 0)
+	u.SetDetail(TrafficDetail{})
+}
+
+func (u *User) ResetTime() {
+	atomic.StoreInt64(&u.UsedMilliTime, 0)
+}
+
+// just keep it for futher uses
+func (u *User) AddToTraffic(traffic int64) {
+	u.addTraffic(nil, uint64(traffic))
+}
+
+func (u *User) AddToTime(MilliTime int64) {
+	_ = atomic.AddInt64(&u.UsedMilliTime, MilliTime)
+}
+
+func (u *User) AddToTrafficInt(traffic int) {
+	u.addTraffic(nil, uint64(traffic))
+}
+
+// addTraffic counts traffic to the total and the given directional counter,
+// and suspends the user once the quota is used up.
+func (u *User) addTraffic(counter *uint64, traffic uint64) {
+	if counter != nil {
+		_ = atomic.AddUint64(counter, traffic)
+	}
+	total := atomic.AddUint64(&u.Traffic, traffic) + atomic.LoadUint64(&u.Remote)
+	if quota := atomic.LoadUint64(&u.Quota); quota > 0 && total >= quota && total-traffic < quota {
+		logf("user is out of quota: %d/%d", total, quota)
+		u.closeAll()
+	}
+}
+
+func (u *User) Set(traffic uint64, usedtime int64) {
+	atomic.StoreUint64(&u.Traffic, traffic)
+	atomic.StoreInt64(&u.UsedMilliTime, usedtime)
+}
+func (u *User) Get() (uint64, int64) {
+	return atomic.LoadUint64(&u.Traffic), atomic.LoadInt64(&u.UsedMilliTime)
+}
+
+func (u *User) GetTraffic() uint64 {
+	return atomic.LoadUint64(&u.Traffic)
+}
+
+// TrafficDetail splits the traffic by direction and protocol.
+// Upload is from the client to the remote, download is the other way round.
+type TrafficDetail struct {
+	TCPUpload   uint64
+	TCPDownload uint64
+	UDPUpload   uint64
+	UDPDownload uint64
+}
+
+func (d TrafficDetail) Upload() uint64 {
+	return d.TCPUpload + d.UDPUpload
+}
+
+func (d TrafficDetail) Download() uint64 {
+	return d.TCPDownload + d.UDPDownload
+}
+
+func (u *User) GetDetail() TrafficDetail {
+	return TrafficDetail{
+		TCPUpload:   atomic.LoadUint64(&u.TCPUpload),
+		TCPDownload: atomic.LoadUint64(&u.TCPDownload),
+		UDPUpload:   atomic.LoadUint64(&u.UDPUpload),
+		UDPDownload: atomic.LoadUint64(&u.UDPDownload),
+	}
+}
+
+// SetDetail restores the directional counters, the total is set by Set.
+func (u *User) SetDetail(d TrafficDetail) {
+	atomic.StoreUint64(&u.TCPUpload, d.TCPUpload)
+	atomic.StoreUint64(&u.TCPDownload, d.TCPDownload)
+	atomic.StoreUint64(&u.UDPUpload, d.UDPUpload)
+	atomic.StoreUint64(&u.UDPDownload, d.UDPDownload)
+}
+
+func (u *User) GetUsedTime() int64 {
+	return atomic.LoadInt64(&u.UsedMilliTime)
+}
+
+func (u *User) SetQuota(quota uint64) {
+	atomic.StoreUint64(&u.Quota, quota)
+	if u.Exceeded() {
+		u.closeAll()
+	}
+}
+
+// SetRemote sets the traffic of the user on the other nodes.
+func (u *User) SetRemote(traffic uint64) {
+	atomic.StoreUint64(&u.Remote, traffic)
+	if u.Exceeded() {
+		u.closeAll()
+	}
+}
+
+func (u *User) GetRemote() uint64 {
+	return atomic.LoadUint64(&u.Remote)
+}
+
+func (u *User) GetQuota() uint64 {
+	return atomic.LoadUint64(&u.Quota)
+}
+
+// Exceeded reports whether the user has used up the quota.
+// Suspended users can't make new connections until the quota is raised or the traffic is reset.
+func (u *User) Exceeded() bool {
+	quota := atomic.LoadUint64(&u.Quota)
+	return quota > 0 && atomic.LoadUint64(&u.Traffic)+atomic.LoadUint64(&u.Remote) >= quota
+}
+
+// track registers a live connection of the user.
+// It returns false if the user is suspended.
+func (u *User) track(c io.Closer) bool {
+	if u.Exceeded() {
+		return false
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.conns[c] = struct{}{}
+	return true
+}
+
+func (u *User) untrack(c io.Closer) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	delete(u.conns, c)
+}
+
+// closeAll tears down all live connections of the user.
+func (u *User) closeAll() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.cancel()
+	u.ctx, u.cancel = context.WithCancel(cb)
+	for c := range u.conns {
+		c.Close()
+		delete(u.conns, c)
+	}
+}
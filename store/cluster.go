@@ -0,0 +1,131 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The operations of the user lifecycle events.
+const (
+	EventAdd    = "add"
+	EventStart  = "start"
+	EventModify = "modify"
+	EventUpdate = "update"
+	EventStop   = "stop"
+	EventExpire = "expire"
+	EventDelete = "delete"
+	EventReset  = "reset"
+)
+
+// Event tells the other nodes about a change to the users. The change is
+// in the store already, the nodes only apply it to the running users.
+type Event struct {
+	Node string `json:"node"`
+	Op   string `json:"op"`
+	Name string `json:"name,omitempty"`
+}
+
+// Cluster is implemented by the stores shared by several nodes.
+type Cluster interface {
+	Node() string
+	Publish(op, name string) error
+	// Subscribe calls f with the events of the other nodes.
+	Subscribe(f func(Event)) error
+	// Traffic sums the counters of a user persisted by the other nodes.
+	Traffic(name string) (Counters, error)
+}
+
+var errNoNode = errors.New("node ID isn't set")
+
+func (s *Redis) nodes() string {
+	return s.prefix + "nodes"
+}
+
+func (s *Redis) trafficKey(node, name string) string {
+	return s.prefix + "traffic:" + node + ":" + name
+}
+
+func (s *Redis) channel() string {
+	return s.prefix + "events"
+}
+
+// SetNode makes the store keep the counters of this node apart, under
+// <prefix>traffic:<node>:<name>. It must be called before any other use.
+func (s *Redis) SetNode(id string) error {
+	if err := s.rdb.SAdd(ctx, s.nodes(), id).Err(); err != nil {
+		return err
+	}
+	s.node = id
+	return nil
+}
+
+func (s *Redis) Node() string {
+	return s.node
+}
+
+func (s *Redis) Publish(op, name string) error {
+	if s.node == "" {
+		return errNoNode
+	}
+	b, err := json.Marshal(Event{Node: s.node, Op: op, Name: name})
+	if err != nil {
+		return err
+	}
+	return s.rdb.Publish(ctx, s.channel(), b).Err()
+}
+
+func (s *Redis) Subscribe(f func(Event)) error {
+	if s.node == "" {
+		return errNoNode
+	}
+	pubsub := s.rdb.Subscribe(ctx, s.channel())
+	// wait for the subscription, so that no event is missed afterwards
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	s.pubsub = pubsub
+	go func() {
+		for msg := range pubsub.Channel() {
+			var ev Event
+			if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+				log.Println("Bad event:", err)
+				continue
+			}
+			if ev.Node != s.node {
+				f(ev)
+			}
+		}
+	}()
+	return nil
+}
+
+// Traffic includes the counters kept in the user hash before the nodes.
+func (s *Redis) Traffic(name string) (Counters, error) {
+	var sum Counters
+	nodes, err := s.rdb.SMembers(ctx, s.nodes()).Result()
+	if err != nil {
+		return sum, err
+	}
+	keys := []string{s.key(name)}
+	for _, node := range nodes {
+		if node != s.node {
+			keys = append(keys, s.trafficKey(node, name))
+		}
+	}
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGetAll(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return sum, err
+	}
+	for _, cmd := range cmds {
+		sum.add(parseCounters(cmd.Val()))
+	}
+	return sum, nil
+}
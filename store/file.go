@@ -0,0 +1,285 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File keeps the users in a JSON file, for single-box deployments
+// without Redis. The file is rewritten on every change.
+type File struct {
+	path  string
+	lock  sync.Mutex
+	users map[string]*fileUser
+}
+
+type fileUser struct {
+	User
+	History []History `json:"history,omitempty"`
+	Hourly  []Bucket  `json:"hourly,omitempty"`
+	Daily   []Bucket  `json:"daily,omitempty"`
+}
+
+// NewFile opens the store at path, which is created on the first write.
+func NewFile(path string) (*File, error) {
+	f := &File{
+		path:  path,
+		users: make(map[string]*fileUser),
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &f.users); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// save writes the file atomically, must be called with the lock held.
+func (f *File) save() error {
+	b, err := json.MarshalIndent(f.users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *File) Load() (map[string]*User, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	users := make(map[string]*User, len(f.users))
+	for name, u := range f.users {
+		c := u.User
+		users[name] = &c
+	}
+	return users, nil
+}
+
+func (f *File) Get(name string) (*User, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := u.User
+	return &c, nil
+}
+
+func (f *File) Put(name string, rec Record) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	old, ok := f.users[name]
+	u := &fileUser{User: User{Record: rec}}
+	if ok {
+		u.Counters, u.History = old.Counters, old.History
+		u.Hourly, u.Daily = old.Hourly, old.Daily
+	}
+	f.users[name] = u
+	if err := f.save(); err != nil {
+		if ok {
+			f.users[name] = old
+		} else {
+			delete(f.users, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *File) ClaimReset(name string, at, next int64) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if u.NextReset != at {
+		return false, nil
+	}
+	u.NextReset = next
+	if err := f.save(); err != nil {
+		u.NextReset = at
+		return false, err
+	}
+	return true, nil
+}
+
+// AddCounters skips the users not stored.
+func (f *File) AddCounters(deltas map[string]Counters) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	old := make(map[string]Counters, len(deltas))
+	for name, d := range deltas {
+		u, ok := f.users[name]
+		if !ok {
+			continue
+		}
+		old[name] = u.Counters
+		u.Counters.add(d)
+	}
+	if len(old) == 0 {
+		return nil
+	}
+	if err := f.save(); err != nil {
+		for name, c := range old {
+			f.users[name].Counters = c
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *File) ResetCounters(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return nil
+	}
+	old := u.Counters
+	u.Counters = Counters{}
+	if err := f.save(); err != nil {
+		u.Counters = old
+		return err
+	}
+	return nil
+}
+
+func (f *File) AddHistory(name string, h History) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return ErrNotFound
+	}
+	old := u.History
+	u.History = append([]History{h}, old...)
+	if len(u.History) > historyLen {
+		u.History = u.History[:historyLen]
+	}
+	if err := f.save(); err != nil {
+		u.History = old
+		return err
+	}
+	return nil
+}
+
+func (f *File) History(name string) ([]History, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]History(nil), u.History...), nil
+}
+
+// addBucket adds d to the last bucket if it starts at start, dropping
+// the buckets older than since. bs is left as is for the rollback.
+func addBucket(bs []Bucket, start, since int64, d Counters) []Bucket {
+	i := 0
+	for i < len(bs) && bs[i].Start < since {
+		i++
+	}
+	res := append([]Bucket(nil), bs[i:]...)
+	if n := len(res); n > 0 && res[n-1].Start == start {
+		res[n-1].Counters.add(d)
+	} else {
+		res = append(res, Bucket{Start: start, Counters: d})
+	}
+	return res
+}
+
+func (f *File) AddUsage(deltas map[string]Counters, t time.Time) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	type saved struct{ hourly, daily []Bucket }
+	old := make(map[string]saved, len(deltas))
+	for name, d := range deltas {
+		u, ok := f.users[name]
+		if !ok {
+			continue
+		}
+		old[name] = saved{u.Hourly, u.Daily}
+		u.Hourly = addBucket(u.Hourly, bucketStart(t, Hour), t.Add(-Retention(Hour)).Unix(), d)
+		u.Daily = addBucket(u.Daily, bucketStart(t, Day), t.Add(-Retention(Day)).Unix(), d)
+	}
+	if len(old) == 0 {
+		return nil
+	}
+	if err := f.save(); err != nil {
+		for name, v := range old {
+			f.users[name].Hourly, f.users[name].Daily = v.hourly, v.daily
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *File) Usage(name string, period time.Duration, from, to int64) ([]Bucket, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var bs []Bucket
+	switch period {
+	case Hour:
+		bs = u.Hourly
+	case Day:
+		bs = u.Daily
+	default:
+		return nil, ErrBadPeriod
+	}
+	var res []Bucket
+	for _, b := range bs {
+		if b.Start >= from && b.Start <= to {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+func (f *File) Delete(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	u, ok := f.users[name]
+	if !ok {
+		return nil
+	}
+	delete(f.users, name)
+	if err := f.save(); err != nil {
+		f.users[name] = u
+		return err
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	return nil
+}
@@ -0,0 +1,471 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ctx = context.Background()
+
+// Redis keeps every user in the hash <prefix>user:<name>, indexed by the
+// set <prefix>users, so that the DB can be shared.
+type Redis struct {
+	rdb    *redis.Client
+	prefix string
+	// node keeps the counters apart per node, see SetNode
+	node   string
+	pubsub *redis.PubSub
+}
+
+const scanCount = 256
+
+// NewRedis connects to Redis, moving the users stored under bare keys by
+// older versions into the prefix the first time.
+func NewRedis(addr, password string, db int, prefix string) (*Redis, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	_ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := rdb.Ping(_ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	s := &Redis{rdb: rdb, prefix: prefix}
+	if err := s.migrate(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Redis) key(name string) string {
+	return s.prefix + "user:" + name
+}
+
+func (s *Redis) index() string {
+	return s.prefix + "users"
+}
+
+// counterKey is where the node keeps the counters of a user, in the user
+// hash itself without a node.
+func (s *Redis) counterKey(name string) string {
+	if s.node == "" {
+		return s.key(name)
+	}
+	return s.trafficKey(s.node, name)
+}
+
+// migrate renames the bare user hashes, left by versions using the whole
+// DB, into the prefix. It runs once per DB.
+func (s *Redis) migrate() error {
+	done := s.prefix + "migrated"
+	if n, err := s.rdb.Exists(ctx, done).Result(); err != nil || n > 0 {
+		return err
+	}
+	moved := 0
+	iter := s.rdb.Scan(ctx, 0, "*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		name := iter.Val()
+		if strings.HasPrefix(name, s.prefix) {
+			continue
+		}
+		if t, err := s.rdb.Type(ctx, name).Result(); err != nil || t != "hash" {
+			continue
+		}
+		v, err := s.rdb.HGetAll(ctx, name).Result()
+		if err != nil {
+			return err
+		}
+		// anything not looking like a user is left alone
+		if _, err := parseUser(v); err != nil {
+			continue
+		}
+		ok, err := s.rdb.RenameNX(ctx, name, s.key(name)).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Printf("Migrate %s: %s exists, skipped", name, s.key(name))
+			continue
+		}
+		if err := s.rdb.SAdd(ctx, s.index(), name).Err(); err != nil {
+			return err
+		}
+		moved++
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if moved > 0 {
+		log.Printf("Migrated %d users under %q", moved, s.prefix)
+	}
+	return s.rdb.Set(ctx, done, time.Now().Unix(), 0).Err()
+}
+
+func parseUser(v map[string]string) (*User, error) {
+	cipher, ok1 := v["cipher"]
+	password, ok2 := v["password"]
+	port, ok3 := v["port"]
+	if !ok1 || !ok2 || !ok3 {
+		return nil, ErrNotFound
+	}
+	// malformed numbers read as 0, like the records written before them
+	u := func(f string) uint64 {
+		n, _ := strconv.ParseUint(v[f], 10, 64)
+		return n
+	}
+	i := func(f string) int64 {
+		n, _ := strconv.ParseInt(v[f], 10, 64)
+		return n
+	}
+	return &User{
+		Record: Record{
+			Cipher:    cipher,
+			Password:  password,
+			Port:      port,
+			Quota:     u("quota"),
+			UpLimit:   i("uplimit"),
+			DownLimit: i("downlimit"),
+			ExpireAt:  i("expireat"),
+			Expired:   v["expired"] == "1",
+
+			ResetDay:    int(i("resetday")),
+			ResetDays:   int(i("resetdays")),
+			ResetAnchor: i("resetanchor"),
+			NextReset:   i("nextreset"),
+		},
+		Counters: parseCounters(v),
+	}, nil
+}
+
+func parseCounters(v map[string]string) Counters {
+	u := func(f string) uint64 {
+		n, _ := strconv.ParseUint(v[f], 10, 64)
+		return n
+	}
+	t, _ := strconv.ParseInt(v["time"], 10, 64)
+	return Counters{
+		Traffic: u("traffic"),
+		Time:    t,
+		TCPUp:   u("tcpup"),
+		TCPDown: u("tcpdown"),
+		UDPUp:   u("udpup"),
+		UDPDown: u("udpdown"),
+	}
+}
+
+func (s *Redis) Load() (map[string]*User, error) {
+	users := map[string]*User{}
+	iter := s.rdb.SScan(ctx, s.index(), 0, "", scanCount).Iterator()
+	for iter.Next(ctx) {
+		name := iter.Val()
+		if _, ok := users[name]; ok {
+			continue
+		}
+		u, err := s.Get(name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users[name] = u
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *Redis) Get(name string) (*User, error) {
+	v, err := s.rdb.HGetAll(ctx, s.key(name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	u, err := parseUser(v)
+	if err != nil || s.node == "" {
+		return u, err
+	}
+	v, err = s.rdb.HGetAll(ctx, s.counterKey(name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	u.Counters = parseCounters(v)
+	return u, nil
+}
+
+func (s *Redis) Put(name string, rec Record) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, s.key(name),
+		"cipher", rec.Cipher,
+		"password", rec.Password,
+		"port", rec.Port,
+		"quota", rec.Quota,
+		"uplimit", rec.UpLimit,
+		"downlimit", rec.DownLimit,
+		"expireat", rec.ExpireAt,
+		"expired", rec.Expired,
+		"resetday", rec.ResetDay,
+		"resetdays", rec.ResetDays,
+		"resetanchor", rec.ResetAnchor,
+		"nextreset", rec.NextReset,
+	)
+	pipe.SAdd(ctx, s.index(), name)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// claimReset moves nextreset from ARGV[1] to ARGV[2] atomically, -1 if
+// the user doesn't exist.
+var claimReset = redis.NewScript(`
+local cur = redis.call("HGET", KEYS[1], "nextreset")
+if not cur then
+	return -1
+end
+if cur ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "nextreset", ARGV[2])
+return 1
+`)
+
+func (s *Redis) ClaimReset(name string, at, next int64) (bool, error) {
+	n, err := claimReset.Run(ctx, s.rdb, []string{s.key(name)}, at, next).Int()
+	if err == nil && n < 0 {
+		err = ErrNotFound
+	}
+	return n == 1, err
+}
+
+// AddCounters uses HINCRBY, so nodes sharing the Redis don't clobber
+// each other.
+func (s *Redis) AddCounters(deltas map[string]Counters) error {
+	pipe := s.rdb.TxPipeline()
+	n := 0
+	for name, d := range deltas {
+		for _, v := range []struct {
+			field string
+			delta int64
+		}{
+			{"traffic", int64(d.Traffic)},
+			{"time", d.Time},
+			{"tcpup", int64(d.TCPUp)},
+			{"tcpdown", int64(d.TCPDown)},
+			{"udpup", int64(d.UDPUp)},
+			{"udpdown", int64(d.UDPDown)},
+		} {
+			if v.delta != 0 {
+				pipe.HIncrBy(ctx, s.counterKey(name), v.field, v.delta)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ResetCounters zeroes the counters of every node, and the ones kept in
+// the user hash before the nodes.
+func (s *Redis) ResetCounters(name string) error {
+	nodes, err := s.rdb.SMembers(ctx, s.nodes()).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{s.key(name)}
+	for _, node := range nodes {
+		keys = append(keys, s.trafficKey(node, name))
+	}
+	pipe := s.rdb.TxPipeline()
+	for _, key := range keys {
+		pipe.HSet(ctx, key,
+			"traffic", 0,
+			"time", 0,
+			"tcpup", 0,
+			"tcpdown", 0,
+			"udpup", 0,
+			"udpdown", 0,
+		)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) historyKey(name string) string {
+	return s.prefix + "history:" + name
+}
+
+// addHistory pushes ARGV[2] to the history KEYS[2] of the user ARGV[1],
+// trimmed to ARGV[3] entries, -1 if the user isn't in the index KEYS[1].
+var addHistory = redis.NewScript(`
+if redis.call("SISMEMBER", KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+redis.call("LPUSH", KEYS[2], ARGV[2])
+redis.call("LTRIM", KEYS[2], 0, ARGV[3] - 1)
+return 1
+`)
+
+func (s *Redis) AddHistory(name string, h History) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	n, err := addHistory.Run(ctx, s.rdb, []string{s.index(), s.historyKey(name)}, name, b, historyLen).Int()
+	if err == nil && n < 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// exists reports ErrNotFound if the user isn't stored.
+func (s *Redis) exists(name string) error {
+	ok, err := s.rdb.SIsMember(ctx, s.index(), name).Result()
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+func (s *Redis) History(name string) ([]History, error) {
+	if err := s.exists(name); err != nil {
+		return nil, err
+	}
+	v, err := s.rdb.LRange(ctx, s.historyKey(name), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]History, 0, len(v))
+	for _, b := range v {
+		var h History
+		if err := json.Unmarshal([]byte(b), &h); err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+func periodName(period time.Duration) string {
+	if period == Hour {
+		return "hour"
+	}
+	return "day"
+}
+
+// usageKey is the hash of the bucket of a user starting at start. It
+// expires with the retention of the period.
+func (s *Redis) usageKey(name string, period time.Duration, start int64) string {
+	return s.prefix + "usage:" + periodName(period) + ":" + name + ":" + strconv.FormatInt(start, 10)
+}
+
+// AddUsage uses HINCRBY, so the buckets sum the traffic of every node.
+func (s *Redis) AddUsage(deltas map[string]Counters, t time.Time) error {
+	pipe := s.rdb.TxPipeline()
+	n := 0
+	for name, d := range deltas {
+		for _, period := range []time.Duration{Hour, Day} {
+			start := bucketStart(t, period)
+			key := s.usageKey(name, period, start)
+			for _, v := range []struct {
+				field string
+				delta int64
+			}{
+				{"traffic", int64(d.Traffic)},
+				{"time", d.Time},
+				{"tcpup", int64(d.TCPUp)},
+				{"tcpdown", int64(d.TCPDown)},
+				{"udpup", int64(d.UDPUp)},
+				{"udpdown", int64(d.UDPDown)},
+			} {
+				if v.delta != 0 {
+					pipe.HIncrBy(ctx, key, v.field, v.delta)
+					n++
+				}
+			}
+			pipe.ExpireAt(ctx, key, time.Unix(start, 0).Add(period+Retention(period)))
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) Usage(name string, period time.Duration, from, to int64) ([]Bucket, error) {
+	if period != Hour && period != Day {
+		return nil, ErrBadPeriod
+	}
+	if err := s.exists(name); err != nil {
+		return nil, err
+	}
+	// nothing older is kept, and the range is walked bucket by bucket
+	now := time.Now()
+	if oldest := now.Add(-Retention(period) - period).Unix(); from < oldest {
+		from = oldest
+	}
+	if latest := now.Add(period).Unix(); to > latest {
+		to = latest
+	}
+	step := int64(period / time.Second)
+	start := bucketStart(time.Unix(from, 0), period)
+	if start < from {
+		start += step
+	}
+	var starts []int64
+	for t := start; t <= to; t += step {
+		starts = append(starts, t)
+	}
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(starts))
+	for i, t := range starts {
+		cmds[i] = pipe.HGetAll(ctx, s.usageKey(name, period, t))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	var bs []Bucket
+	for i, cmd := range cmds {
+		if len(cmd.Val()) > 0 {
+			bs = append(bs, Bucket{Start: starts[i], Counters: parseCounters(cmd.Val())})
+		}
+	}
+	return bs, nil
+}
+
+func (s *Redis) Delete(name string) error {
+	nodes, err := s.rdb.SMembers(ctx, s.nodes()).Result()
+	if err != nil {
+		return err
+	}
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, s.key(name), s.historyKey(name))
+	for _, node := range nodes {
+		pipe.Del(ctx, s.trafficKey(node, name))
+	}
+	pipe.SRem(ctx, s.index(), name)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) Close() error {
+	if s.pubsub != nil {
+		s.pubsub.Close()
+	}
+	return s.rdb.Close()
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound means the user isn't stored, or its record is incomplete.
+var ErrNotFound = errors.New("user not found")
+
+// Record is the settings of a user.
+type Record struct {
+	Cipher    string `json:"cipher"`
+	Password  string `json:"password"`
+	Port      string `json:"port"`
+	Quota     uint64 `json:"quota"`
+	UpLimit   int64  `json:"uplimit"`
+	DownLimit int64  `json:"downlimit"`
+	// ExpireAt is in Unix seconds, 0 never expires.
+	ExpireAt int64 `json:"expireat"`
+	// Expired is set once the user has been stopped for its expiry.
+	Expired bool `json:"expired"`
+	// The counters are reset monthly on ResetDay, or every ResetDays
+	// days from ResetAnchor, next at NextReset in Unix seconds.
+	ResetDay    int   `json:"resetday"`
+	ResetDays   int   `json:"resetdays"`
+	ResetAnchor int64 `json:"resetanchor"`
+	NextReset   int64 `json:"nextreset"`
+}
+
+// Counters is the traffic of a user.
+type Counters struct {
+	Traffic uint64 `json:"traffic"`
+	Time    int64  `json:"time"`
+	TCPUp   uint64 `json:"tcpup"`
+	TCPDown uint64 `json:"tcpdown"`
+	UDPUp   uint64 `json:"udpup"`
+	UDPDown uint64 `json:"udpdown"`
+}
+
+type User struct {
+	Record
+	Counters
+}
+
+// History is the counters of a user when they were reset.
+type History struct {
+	At int64 `json:"at"`
+	Counters
+}
+
+// historyLen is how many resets are kept per user.
+const historyLen = 120
+
+// Bucket is the traffic of a user over the hour or day from Start, in
+// Unix seconds.
+type Bucket struct {
+	Start int64 `json:"start"`
+	Counters
+}
+
+// The periods of the usage buckets, days are in UTC.
+const (
+	Hour = time.Hour
+	Day  = 24 * time.Hour
+)
+
+// ErrBadPeriod means the period is neither Hour nor Day.
+var ErrBadPeriod = errors.New("invalid period")
+
+// Retention is how long the buckets of a period are kept.
+func Retention(period time.Duration) time.Duration {
+	if period == Hour {
+		return 31 * Day
+	}
+	return 400 * Day
+}
+
+func bucketStart(t time.Time, period time.Duration) int64 {
+	return t.Truncate(period).Unix()
+}
+
+func (c *Counters) add(d Counters) {
+	c.Traffic += d.Traffic
+	c.Time += d.Time
+	c.TCPUp += d.TCPUp
+	c.TCPDown += d.TCPDown
+	c.UDPUp += d.UDPUp
+	c.UDPDown += d.UDPDown
+}
+
+// Store keeps the users across restarts.
+type Store interface {
+	// Load returns every complete user record.
+	Load() (map[string]*User, error)
+	Get(name string) (*User, error)
+	// Put stores the settings of a user, keeping its counters.
+	Put(name string, rec Record) error
+	// ClaimReset moves NextReset of a user from at to next, unless another
+	// node has already. It tells whether the reset is the caller's.
+	ClaimReset(name string, at, next int64) (bool, error)
+	// AddCounters adds the deltas to the counters of the users at once.
+	AddCounters(deltas map[string]Counters) error
+	// ResetCounters zeroes the counters of a user, on every node.
+	ResetCounters(name string) error
+	AddHistory(name string, h History) error
+	// History returns the latest resets first.
+	History(name string) ([]History, error)
+	// AddUsage adds the deltas to the hourly and daily buckets at t.
+	AddUsage(deltas map[string]Counters, t time.Time) error
+	// Usage returns the buckets of period from from to to, oldest first.
+	Usage(name string, period time.Duration, from, to int64) ([]Bucket, error)
+	Delete(name string) error
+	Close() error
+}
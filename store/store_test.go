@@ -0,0 +1,221 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// backends opens an empty store of every type.
+var backends = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"file", func(t *testing.T) Store {
+		s, err := NewFile(filepath.Join(t.TempDir(), "users.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}},
+	{"redis", func(t *testing.T) Store {
+		m := miniredis.RunT(t)
+		s, err := NewRedis(m.Addr(), "", 0, "ss:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}},
+}
+
+func TestStore(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			testStore(t, b.open(t))
+		})
+	}
+}
+
+// testStore checks the semantics every Store shares, on an empty s.
+func testStore(t *testing.T, s Store) {
+	if _, err := s.Get("u"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of an unknown user: %v", err)
+	}
+	if _, err := s.History("u"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("history of an unknown user: %v", err)
+	}
+	if _, err := s.Usage("u", Hour, 0, time.Now().Unix()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("usage of an unknown user: %v", err)
+	}
+	if err := s.AddHistory("u", History{At: 1}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("history added to an unknown user: %v", err)
+	}
+	if err := s.Delete("u"); err != nil {
+		t.Fatalf("delete of an unknown user: %v", err)
+	}
+
+	rec := Record{Cipher: "AES-256-GCM", Password: "p", Port: "8388", Quota: 1 << 30, ExpireAt: 100, NextReset: 200}
+	if err := s.Put("u", rec); err != nil {
+		t.Fatal(err)
+	}
+	c := Counters{Traffic: 10, Time: 1, TCPUp: 2, TCPDown: 3, UDPUp: 4, UDPDown: 1}
+	if err := s.AddCounters(map[string]Counters{"u": c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCounters(map[string]Counters{"u": c}); err != nil {
+		t.Fatal(err)
+	}
+	twice := c
+	twice.add(c)
+	want := &User{Record: rec, Counters: twice}
+	if u, err := s.Get("u"); err != nil || !reflect.DeepEqual(u, want) {
+		t.Fatalf("get: %+v %v, want %+v", u, err, want)
+	}
+	// Put keeps the counters
+	rec.Port = "8389"
+	if err := s.Put("u", rec); err != nil {
+		t.Fatal(err)
+	}
+	want.Record = rec
+	users, err := s.Load()
+	if err != nil || !reflect.DeepEqual(users, map[string]*User{"u": want}) {
+		t.Fatalf("load: %+v %v", users, err)
+	}
+
+	if hs, err := s.History("u"); err != nil || len(hs) != 0 {
+		t.Fatalf("history of a new user: %v %v", hs, err)
+	}
+	for at := int64(1); at <= historyLen+1; at++ {
+		if err := s.AddHistory("u", History{At: at, Counters: c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hs, err := s.History("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != historyLen || hs[0].At != historyLen+1 || hs[len(hs)-1].At != 2 || hs[0].Counters != c {
+		t.Fatalf("history: %d entries from %d to %d", len(hs), hs[0].At, hs[len(hs)-1].At)
+	}
+	if err := s.ResetCounters("u"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := s.Get("u"); err != nil || u.Counters != (Counters{}) {
+		t.Fatalf("counters after reset: %+v %v", u, err)
+	}
+
+	now := time.Now()
+	hour := now.Truncate(Hour)
+	for _, at := range []time.Time{hour.Add(-Hour), hour, hour.Add(time.Minute)} {
+		if err := s.AddUsage(map[string]Counters{"u": c}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs, err := s.Usage("u", Hour, now.Add(-Day).Unix(), now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 2 || bs[0].Start != hour.Add(-Hour).Unix() || bs[0].Counters != c ||
+		bs[1].Start != hour.Unix() || bs[1].Counters != twice {
+		t.Fatalf("hourly usage: %+v", bs)
+	}
+	if bs, err := s.Usage("u", Hour, hour.Unix(), hour.Unix()); err != nil || len(bs) != 1 {
+		t.Fatalf("usage of one hour: %+v %v", bs, err)
+	}
+	if _, err := s.Usage("u", time.Minute, 0, now.Unix()); !errors.Is(err, ErrBadPeriod) {
+		t.Fatalf("usage by the minute: %v", err)
+	}
+
+	if err := s.Delete("u"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("u"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of a deleted user: %v", err)
+	}
+	if _, err := s.History("u"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("history of a deleted user: %v", err)
+	}
+	if users, err := s.Load(); err != nil || len(users) != 0 {
+		t.Fatalf("load after delete: %+v %v", users, err)
+	}
+}
+
+func TestClaimReset(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.open(t)
+			if _, err := s.ClaimReset("nobody", 1, 2); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("claim of an unknown user: %v", err)
+			}
+			for at := int64(100); at < 120; at++ {
+				if err := s.Put("u", Record{Port: "8388", NextReset: at}); err != nil {
+					t.Fatal(err)
+				}
+				var wg sync.WaitGroup
+				var claims int32
+				for i := 0; i < 2; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						ok, err := s.ClaimReset("u", at, at+1000)
+						if err != nil {
+							t.Error(err)
+						}
+						if ok {
+							atomic.AddInt32(&claims, 1)
+						}
+					}()
+				}
+				wg.Wait()
+				if claims != 1 {
+					t.Fatalf("reset at %d claimed %d times", at, claims)
+				}
+				u, err := s.Get("u")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if u.NextReset != at+1000 {
+					t.Fatalf("next reset %d, want %d", u.NextReset, at+1000)
+				}
+			}
+		})
+	}
+}
+
+func TestFileRollback(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFile(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("u", Record{Port: "8388"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c := Counters{Traffic: 10}
+	if err := s.AddUsage(map[string]Counters{"u": c}, now); err != nil {
+		t.Fatal(err)
+	}
+	// nothing can be saved once the directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddUsage(map[string]Counters{"u": c}, now); err == nil {
+		t.Fatal("usage added without saving")
+	}
+	for _, period := range []time.Duration{Hour, Day} {
+		bs, err := s.Usage("u", period, 0, now.Unix())
+		if err != nil || len(bs) != 1 || bs[0].Counters != c {
+			t.Fatalf("usage after the rollback: %+v %v", bs, err)
+		}
+	}
+}
@@ -1,100 +1,195 @@
-package usermap
-
-import (
-	"fmt"
-	"sync"
-
-	"github.com/BishiNET/ss-server/server"
-)
-
-type UserMap map[string]*server.User
-
-var (
-	// Read Lock protects the user map for reading.
-	// atomic protects the internal vars.
-	rwlock sync.RWMutex
-)
-
-func NewMap() UserMap {
-	return UserMap{}
-}
-
-func (u UserMap) Exists(name string) bool {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	_, ok := u[name]
-	return ok
-}
-func (u UserMap) AddUser(name, cipher, password, port string) error {
-	user_entry, err := server.New(cipher, fmt.Sprintf("0.0.0.0:%s", port), password)
-	if err != nil {
-		return err
-	}
-	rwlock.Lock()
-	defer rwlock.Unlock()
-	u[name] = user_entry
-	return nil
-}
-
-func (u UserMap) DeleteUser(name string) {
-	rwlock.Lock()
-	defer rwlock.Unlock()
-	u[name].Shutdown()
-	delete(u, name)
-}
-
-func (u UserMap) ResetUser(name string) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	u[name].Reset()
-}
-
-func (u UserMap) ResetUserTraffic(name string) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	u[name].ResetTraffic()
-}
-func (u UserMap) ResetUserTime(name string) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	u[name].ResetTime()
-}
-
-func (u UserMap) ResetAll() {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	for _, v := range u {
-		v.Reset()
-	}
-}
-func (u UserMap) GetUser(name string) (uint64, int64) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	return u[name].Get()
-}
-
-func (u UserMap) GetUserTraffic(name string) uint64 {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	return u[name].GetTraffic()
-}
-
-func (u UserMap) GetUserUsedTime(name string) int64 {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	return u[name].GetUsedTime()
-}
-
-func (u UserMap) SetUser(name string, traffic uint64, time int64) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	u[name].Set(traffic, time)
-}
-
-func (u UserMap) GetAll(executor func(name string, traffic uint64, usedtime int64)) {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	for k, v := range u {
-		executor(k, v.GetTraffic(), v.GetUsedTime())
-	}
-}
+package usermap
+
+import (
+	"net"
+	"sync"
+
+	"github.com/BishiNET/ss-server/server"
+)
+
+// UserMap holds the running users by name. The methods taking a name do
+// nothing, or return zero values, if the user has been deleted meanwhile.
+type UserMap map[string]*server.User
+
+var (
+	// Read Lock protects the user map for reading.
+	// atomic protects the internal vars.
+	rwlock sync.RWMutex
+	// bindIP is the IP the ports of the users listen on.
+	bindIP = "0.0.0.0"
+)
+
+// SetBindIP sets the listening IP of the users added afterwards.
+func SetBindIP(ip string) {
+	bindIP = ip
+}
+
+func NewMap() UserMap {
+	return UserMap{}
+}
+
+func (u UserMap) Exists(name string) bool {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	_, ok := u[name]
+	return ok
+}
+
+// Get returns the user of name, nil if there is none.
+func (u UserMap) Get(name string) *server.User {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	return u[name]
+}
+
+func (u UserMap) AddUser(name, cipher, password, port string) error {
+	user_entry, err := server.New(cipher, net.JoinHostPort(bindIP, port), password)
+	if err != nil {
+		return err
+	}
+	rwlock.Lock()
+	defer rwlock.Unlock()
+	u[name] = user_entry
+	return nil
+}
+
+func (u UserMap) DeleteUser(name string) {
+	rwlock.Lock()
+	defer rwlock.Unlock()
+	if v, ok := u[name]; ok {
+		v.Shutdown()
+	}
+	delete(u, name)
+}
+
+func (u UserMap) ResetUser(name string) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.Reset()
+	}
+}
+
+func (u UserMap) ResetUserTraffic(name string) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.ResetTraffic()
+	}
+}
+func (u UserMap) ResetUserTime(name string) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.ResetTime()
+	}
+}
+
+func (u UserMap) ResetAll() {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	for _, v := range u {
+		v.Reset()
+	}
+}
+func (u UserMap) GetUser(name string) (uint64, int64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.Get()
+	}
+	return 0, 0
+}
+
+func (u UserMap) GetUserTraffic(name string) uint64 {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.GetTraffic()
+	}
+	return 0
+}
+
+func (u UserMap) GetUserUsedTime(name string) int64 {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.GetUsedTime()
+	}
+	return 0
+}
+
+func (u UserMap) SetUser(name string, traffic uint64, time int64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.Set(traffic, time)
+	}
+}
+
+func (u UserMap) SetUserQuota(name string, quota uint64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.SetQuota(quota)
+	}
+}
+
+func (u UserMap) SetUserRemote(name string, traffic uint64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.SetRemote(traffic)
+	}
+}
+
+func (u UserMap) GetUserQuota(name string) uint64 {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.GetQuota()
+	}
+	return 0
+}
+
+func (u UserMap) SetUserRateLimit(name string, up, down int64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.SetRateLimit(up, down)
+	}
+}
+
+func (u UserMap) GetUserRateLimit(name string) (int64, int64) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.GetRateLimit()
+	}
+	return 0, 0
+}
+
+func (u UserMap) GetUserDetail(name string) server.TrafficDetail {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		return v.GetDetail()
+	}
+	return server.TrafficDetail{}
+}
+
+func (u UserMap) SetUserDetail(name string, detail server.TrafficDetail) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	if v, ok := u[name]; ok {
+		v.SetDetail(detail)
+	}
+}
+
+func (u UserMap) GetAll(executor func(name string, traffic uint64, usedtime int64, detail server.TrafficDetail)) {
+	rwlock.RLock()
+	defer rwlock.RUnlock()
+	for k, v := range u {
+		executor(k, v.GetTraffic(), v.GetUsedTime(), v.GetDetail())
+	}
+}